@@ -8,17 +8,21 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/soar/sr_exhibit/cache"
 	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/progress"
 )
 
 const (
 	DefaultBaseURL = "https://www.speedrun.com/api/v1"
 	DefaultTimeout = 30 * time.Second
+	DefaultTop     = 100 // Default number of places fetched per leaderboard
 )
 
 // Client represents the API client
@@ -26,7 +30,9 @@ type Client struct {
 	BaseURL     string
 	HTTPClient  *http.Client
 	playerCache *cache.PlayerCache
-	cacheOnce   sync.Once // Ensures cache is initialized only once
+	metadata    *cache.MetadataCache // Game, category and variable lookups, nil to always fetch
+	cacheOnce   sync.Once            // Ensures cache is initialized only once
+	calls       atomic.Int64         // Number of API requests sent
 }
 
 // NewClient creates a new API client
@@ -56,6 +62,35 @@ func (c *Client) GetPlayerCache() *cache.PlayerCache {
 	return c.playerCache
 }
 
+// SetMetadataCache sets the cache of game, category and variable lookups
+func (c *Client) SetMetadataCache(mc *cache.MetadataCache) {
+	c.metadata = mc
+}
+
+// Calls returns the number of API requests sent by the client
+func (c *Client) Calls() int64 {
+	return c.calls.Load()
+}
+
+// cachedMetadata decodes a cached lookup into v, false on a miss or without a metadata cache
+func (c *Client) cachedMetadata(key string, ttl time.Duration, v any) bool {
+	return c.metadata != nil && c.metadata.Get(key, ttl, v)
+}
+
+// cacheMetadata stores a lookup; a failure only costs an API call on the next run
+func (c *Client) cacheMetadata(key string, v any) {
+	if c.metadata == nil {
+		return
+	}
+	err := c.metadata.Set(key, v)
+	if err == nil {
+		err = c.metadata.Save()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save metadata cache: %v\n", err)
+	}
+}
+
 // GetGames gets the game list
 func (c *Client) GetGames(ctx context.Context, offset int) ([]models.Game, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/games", nil)
@@ -83,6 +118,8 @@ func (c *Client) GetGames(ctx context.Context, offset int) ([]models.Game, error
 func (c *Client) GetAllGames(ctx context.Context) ([]models.Game, error) {
 	var allGames []models.Game
 	offset := 0
+	task := progress.Start(ctx, "Loading game list", 0)
+	defer task.Finish()
 
 	for {
 		games, err := c.GetGames(ctx, offset)
@@ -91,6 +128,7 @@ func (c *Client) GetAllGames(ctx context.Context) ([]models.Game, error) {
 		}
 
 		allGames = append(allGames, games...)
+		task.Add(len(games))
 
 		if len(games) < 200 {
 			break
@@ -103,7 +141,23 @@ func (c *Client) GetAllGames(ctx context.Context) ([]models.Game, error) {
 }
 
 // SearchGameByName searches for a game by name
+// Results are served from the metadata cache for cache.GameTTL
 func (c *Client) SearchGameByName(ctx context.Context, name string) (*models.Game, error) {
+	key := "game:" + strings.ToLower(name)
+	var cached models.Game
+	if c.cachedMetadata(key, cache.GameTTL, &cached) {
+		return &cached, nil
+	}
+	game, err := c.searchGameByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.cacheMetadata(key, game)
+	return game, nil
+}
+
+// searchGameByName looks up a game by abbreviation, ID, exact name, then fuzzy name
+func (c *Client) searchGameByName(ctx context.Context, name string) (*models.Game, error) {
 	// First try direct abbreviation access (speedrun.com supports /games/{abbreviation})
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
 		c.BaseURL+"/games/"+url.PathEscape(name), nil)
@@ -158,11 +212,18 @@ func (c *Client) SearchGameByName(ctx context.Context, name string) (*models.Gam
 		}
 	}
 
-	return nil, fmt.Errorf("game not found: %s", name)
+	return nil, notFound("game not found: %s", name)
 }
 
 // GetCategories gets game categories
+// Results are served from the metadata cache for cache.CategoryTTL
 func (c *Client) GetCategories(ctx context.Context, gameID string) ([]models.Category, error) {
+	key := "categories:" + gameID
+	var cached []models.Category
+	if c.cachedMetadata(key, cache.CategoryTTL, &cached) {
+		return cached, nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
 		c.BaseURL+"/games/"+url.PathEscape(gameID)+"/categories", nil)
 	if err != nil {
@@ -174,6 +235,7 @@ func (c *Client) GetCategories(ctx context.Context, gameID string) ([]models.Cat
 		return nil, err
 	}
 
+	c.cacheMetadata(key, result.Data)
 	return result.Data, nil
 }
 
@@ -190,11 +252,17 @@ func (c *Client) GetCategoryByName(ctx context.Context, gameID string, categoryN
 		}
 	}
 
-	return nil, fmt.Errorf("category not found: %s", categoryName)
+	return nil, notFound("category not found: %s", categoryName)
+}
+
+// LeaderboardOptions represents optional leaderboard query parameters
+type LeaderboardOptions struct {
+	Timing string // Timing method used for ranking and displayed times, empty for the game's primary timing
+	Top    int    // Number of places to fetch, 0 for DefaultTop
 }
 
 // GetLeaderboard gets leaderboard data
-func (c *Client) GetLeaderboard(ctx context.Context, gameID, categoryID string, varFilters map[string]string) (*models.LeaderboardData, error) {
+func (c *Client) GetLeaderboard(ctx context.Context, gameID, categoryID string, varFilters map[string]string, opts LeaderboardOptions) (*models.LeaderboardData, error) {
 	reqURL := fmt.Sprintf("%s/leaderboards/%s/category/%s",
 		c.BaseURL, url.PathEscape(gameID), url.PathEscape(categoryID))
 
@@ -205,8 +273,15 @@ func (c *Client) GetLeaderboard(ctx context.Context, gameID, categoryID string,
 
 	// Add parameters to get more data
 	q := req.URL.Query()
-	q.Add("top", "100")      // Get top 100
-	q.Add("embed", "players") // Get player data
+	top := opts.Top
+	if top <= 0 {
+		top = DefaultTop
+	}
+	q.Add("top", strconv.Itoa(top))
+	if opts.Timing != "" {
+		q.Add("timing", opts.Timing)
+	}
+	q.Add("embed", "players,platforms") // Get player data and platform names
 
 	// Add variable filter parameters
 	for varID, varValue := range varFilters {
@@ -220,6 +295,18 @@ func (c *Client) GetLeaderboard(ctx context.Context, gameID, categoryID string,
 		return nil, err
 	}
 
+	// Display the times of the requested timing method
+	// Runs without a time for that method can't be ranked by it and are dropped
+	if opts.Timing != "" {
+		runs := result.Data.Runs[:0]
+		for _, run := range result.Data.Runs {
+			if run.Run.Times.UseTiming(opts.Timing) {
+				runs = append(runs, run)
+			}
+		}
+		result.Data.Runs = runs
+	}
+
 	// If API didn't return player data, we need to fetch it manually
 	if len(result.Data.Players.M) == 0 {
 		result.Data.Players.M = make(map[string]models.PlayerData)
@@ -285,12 +372,15 @@ func (c *Client) GetLeaderboard(ctx context.Context, gameID, categoryID string,
 		}
 
 		// Collect results
+		task := progress.Start(ctx, "Fetching players", len(idsToFetch))
 		for i := 0; i < len(idsToFetch); i++ {
 			r := <-results
 			if r.data != nil {
 				result.Data.Players.M[r.id] = *r.data
 			}
+			task.Add(1)
 		}
+		task.Finish()
 
 		// Save cache to file
 		if c.playerCache != nil {
@@ -304,8 +394,45 @@ func (c *Client) GetLeaderboard(ctx context.Context, gameID, categoryID string,
 	return &result.Data, nil
 }
 
+// GetRecords gets the top runs of a full-game category from the records endpoint
+// The records board is not filtered by subcategory, it ranks the runs of every subcategory together
+// Records are never cached, they cross-check the leaderboard endpoint
+func (c *Client) GetRecords(ctx context.Context, categoryID string, top int) ([]models.RunEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.BaseURL+"/categories/"+url.PathEscape(categoryID)+"/records", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	q := req.URL.Query()
+	q.Add("top", strconv.Itoa(top))
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Data []struct {
+			Level *string           `json:"level"` // Null for the full-game board
+			Runs  []models.RunEntry `json:"runs"`
+		} `json:"data"`
+	}
+	if err := c.doRequest(req, &result); err != nil {
+		return nil, err
+	}
+	for _, board := range result.Data {
+		if board.Level == nil {
+			return board.Runs, nil
+		}
+	}
+	return nil, nil
+}
+
 // GetVariables gets game variables (subcategories)
+// Results are served from the metadata cache for cache.VariableTTL
 func (c *Client) GetVariables(ctx context.Context, gameID string) ([]models.Variable, error) {
+	key := "variables:" + gameID
+	var cached []models.Variable
+	if c.cachedMetadata(key, cache.VariableTTL, &cached) {
+		return cached, nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
 		c.BaseURL+"/games/"+url.PathEscape(gameID)+"/variables", nil)
 	if err != nil {
@@ -320,6 +447,7 @@ func (c *Client) GetVariables(ctx context.Context, gameID string) ([]models.Vari
 		return nil, err
 	}
 
+	c.cacheMetadata(key, result.Data)
 	return result.Data, nil
 }
 
@@ -354,11 +482,11 @@ func (c *Client) ResolveSubcategoriesByName(ctx context.Context, gameID, categor
 		}
 
 		if len(matches) == 0 {
-			return nil, fmt.Errorf("subcategory not found: %s", varName)
+			return nil, notFound("subcategory not found: %s", varName)
 		}
 
 		if len(matches) > 1 {
-			return nil, fmt.Errorf("ambiguous subcategory name '%s': multiple variables match", varName)
+			return nil, ambiguous("ambiguous subcategory name '%s': multiple variables match", varName)
 		}
 
 		matchedVar = &matches[0]
@@ -379,12 +507,12 @@ func (c *Client) ResolveSubcategoriesByName(ctx context.Context, gameID, categor
 			for _, val := range matchedVar.Values.Values {
 				availableLabels = append(availableLabels, val.Label)
 			}
-			return nil, fmt.Errorf("value '%s' not found for subcategory '%s'. Available options: %s",
+			return nil, notFound("value '%s' not found for subcategory '%s'. Available options: %s",
 				valueLabel, varName, strings.Join(availableLabels, ", "))
 		}
 
 		if len(valueMatches) > 1 {
-			return nil, fmt.Errorf("ambiguous value '%s' for subcategory '%s': multiple values match", valueLabel, varName)
+			return nil, ambiguous("ambiguous value '%s' for subcategory '%s': multiple values match", valueLabel, varName)
 		}
 
 		matchedValueID = valueMatches[0]
@@ -424,11 +552,11 @@ func (c *Client) ResolveSubcategoryByValue(ctx context.Context, gameID, category
 	}
 
 	if len(matches) == 0 {
-		return nil, fmt.Errorf("no variable containing 'Subcategory'/'Subcategories' found for this category")
+		return nil, notFound("no variable containing 'Subcategory'/'Subcategories' found for this category")
 	}
 
 	if len(matches) > 1 {
-		return nil, fmt.Errorf("ambiguous: multiple variables containing 'Subcategory'/'Subcategories' found")
+		return nil, ambiguous("ambiguous: multiple variables containing 'Subcategory'/'Subcategories' found")
 	}
 
 	matchedVar = &matches[0]
@@ -449,12 +577,12 @@ func (c *Client) ResolveSubcategoryByValue(ctx context.Context, gameID, category
 		for _, val := range matchedVar.Values.Values {
 			availableLabels = append(availableLabels, val.Label)
 		}
-		return nil, fmt.Errorf("value '%s' not found for Subcategory. Available options: %s",
+		return nil, notFound("value '%s' not found for Subcategory. Available options: %s",
 			valueLabel, strings.Join(availableLabels, ", "))
 	}
 
 	if len(valueMatches) > 1 {
-		return nil, fmt.Errorf("ambiguous value '%s': multiple values match", valueLabel)
+		return nil, ambiguous("ambiguous value '%s': multiple values match", valueLabel)
 	}
 
 	matchedValueID = valueMatches[0]
@@ -485,6 +613,7 @@ func (c *Client) doRequest(req *http.Request, result any) error {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "sr_exhibit/1.0")
 
+	c.calls.Add(1)
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
@@ -493,11 +622,11 @@ func (c *Client) doRequest(req *http.Request, result any) error {
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned error status code %d: %s", resp.StatusCode, string(body))
+		return statusError(resp, body)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+		return &ErrDecode{Err: err}
 	}
 
 	return nil
@@ -521,3 +650,31 @@ func (c *Client) GetRunDetails(ctx context.Context, runID string) (*models.RunDa
 
 	return &result.Data, nil
 }
+
+// GetPlatformName gets the name of a platform
+// Results are served from the metadata cache for cache.GameTTL
+func (c *Client) GetPlatformName(ctx context.Context, platformID string) (string, error) {
+	key := "platform:" + platformID
+	var cached string
+	if c.cachedMetadata(key, cache.GameTTL, &cached) {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.BaseURL+"/platforms/"+url.PathEscape(platformID), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var result struct {
+		Data struct {
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	if err := c.doRequest(req, &result); err != nil {
+		return "", err
+	}
+
+	c.cacheMetadata(key, result.Data.Name)
+	return result.Data.Name, nil
+}
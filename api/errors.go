@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Lookup failures: the game, category or subcategory given by the user matches nothing, or more than one thing
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrAmbiguous = errors.New("ambiguous match")
+)
+
+// StatusError is returned when the API responds with a non-2xx status code not covered by
+// ErrRateLimited or ErrServer; a 404 also matches ErrNotFound
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned error status code %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// ErrRateLimited is returned when the API rate limit is reached (HTTP 429)
+type ErrRateLimited struct {
+	RetryAfter time.Duration // Wait requested by the Retry-After header, 0 if not given
+}
+
+func (e *ErrRateLimited) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("API rate limit reached, retry after %s", e.RetryAfter)
+	}
+	return "API rate limit reached"
+}
+
+// ErrServer is returned when the API fails with a 5xx status code
+type ErrServer struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ErrServer) Error() string {
+	return fmt.Sprintf("API server error %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrDecode is returned when an API response can't be parsed
+type ErrDecode struct {
+	Err error
+}
+
+func (e *ErrDecode) Error() string {
+	return fmt.Sprintf("failed to parse response: %v", e.Err)
+}
+
+func (e *ErrDecode) Unwrap() error {
+	return e.Err
+}
+
+// statusError converts a non-2xx response to ErrRateLimited, ErrServer or StatusError
+func statusError(resp *http.Response, body []byte) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &ErrRateLimited{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	case resp.StatusCode >= 500:
+		return &ErrServer{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// parseRetryAfter parses a Retry-After header: delay in seconds or an HTTP date, 0 if absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// Retryable reports whether a request failing with err may succeed when repeated:
+// rate limits, server errors and network failures; lookup failures, other 4xx and decode errors are not
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status *StatusError
+	var decode *ErrDecode
+	if errors.As(err, &status) || errors.As(err, &decode) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrAmbiguous) {
+		return false
+	}
+	return true
+}
+
+// lookupError keeps the message of a lookup failure while matching its kind with errors.Is
+type lookupError struct {
+	kind error
+	msg  string
+}
+
+func (e *lookupError) Error() string {
+	return e.msg
+}
+
+func (e *lookupError) Unwrap() error {
+	return e.kind
+}
+
+// notFound returns an ErrNotFound lookup failure with a formatted message
+func notFound(format string, args ...any) error {
+	return &lookupError{kind: ErrNotFound, msg: fmt.Sprintf(format, args...)}
+}
+
+// ambiguous returns an ErrAmbiguous lookup failure with a formatted message
+func ambiguous(format string, args ...any) error {
+	return &lookupError{kind: ErrAmbiguous, msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soar/sr_exhibit/api"
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/export"
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/progress"
+)
+
+const (
+	// defaultHubOutput is the default hub page path in batch mode
+	defaultHubOutput = "./output/index.html"
+	// defaultHubTitle is the default hub page title
+	defaultHubTitle = "Leaderboards"
+	// hubTopRuns is the number of top runs listed per leaderboard on the hub page
+	hubTopRuns = 3
+)
+
+// batchBoard represents one fetched leaderboard waiting to be rendered
+type batchBoard struct {
+	result   *boardResult
+	output   string
+	template string
+	page     models.PageConfig // Page overrides of the entry merged over the top-level ones
+}
+
+// runBatch generates every leaderboard listed in the config, then the hub page
+// cliTemplate is the --template flag, which takes priority over the defaults block and the top-level template
+func runBatch(ctx context.Context, config models.Config, timeout time.Duration, clk clock.Clock, cliTemplate string, cacheDir string, lbCache *cache.LeaderboardCache, snapshots *cache.SnapshotStore, useCache, refreshCache bool) error {
+	s := newSession(config, timeout, clk, cacheDir, lbCache, snapshots, useCache, refreshCache)
+	s.batch = true
+	defer s.recordUsage(ctx, "batch")
+
+	failed := 0
+	failCode := exitOK // Exit code of the first failure
+	fail := func(code int) {
+		failed++
+		s.failed++
+		if failCode == exitOK {
+			failCode = code
+		}
+	}
+	outputDir := batchOutputDir(config.Defaults)
+
+	known := cache.NewKnownValues(cacheDir)
+	entries := s.expandCategories(ctx, config.Leaderboards, fail)
+	entries = s.expandSubcategories(ctx, entries, known, fail)
+	if err := known.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	total := len(entries) + failed // Entries that failed to expand count as failed leaderboards
+
+	// Fetch all leaderboards first, world record holders are computed across all of them
+	var boards []batchBoard
+	hubOutput := config.Hub.Output
+	if hubOutput == "" {
+		hubOutput = filepath.Join(outputDir, filepath.Base(defaultHubOutput))
+	}
+	outputs := map[string]string{filepath.Clean(hubOutput): "the hub page"} // Cleaned output path -> what writes it
+	for i, entry := range entries {
+		entry = applyDefaults(entry, config.Defaults)
+		fmt.Printf("\n[%d/%d] %s - %s\n", i+1, total, entry.Game, entry.Category)
+		if entry.Game == "" || entry.Category == "" {
+			fmt.Fprintf(os.Stderr, "Error: leaderboard #%d: game and category are required in batch mode\n", i+1)
+			fail(exitConfig)
+			continue
+		}
+
+		result, err := s.fetchBoard(ctx, boardSpec{
+			Game:        entry.Game,
+			Category:    entry.Category,
+			Subcategory: entry.Subcategory,
+			Variables:   entry.Variables,
+			Timing:      entry.Timing,
+			Top:         entry.Top,
+			Merge:       entry.Merge,
+			Scoring:     entry.Scoring,
+			PerCountry:  entry.PerCountry,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: %v\n", entry.Game, entry.Category, err)
+			fail(exitCode(err))
+			continue
+		}
+
+		board := batchBoard{
+			result:   result,
+			output:   entry.Output,
+			template: boardTemplate(entry, cliTemplate, config),
+			page:     entry.Page.Merge(config.Page),
+		}
+		if board.output == "" {
+			board.output = filepath.Join(outputDir, boardSlug(result)+".html")
+		}
+		if path, prev := outputCollision(outputs, boardOutputs(config, board.output)); prev != "" {
+			fmt.Fprintf(os.Stderr, "Error: leaderboard #%d: output collision, %s is already written by %s, set a different output\n", i+1, path, prev)
+			fail(exitConfig)
+			continue
+		}
+		for _, path := range boardOutputs(config, board.output) {
+			outputs[filepath.Clean(path)] = fmt.Sprintf("leaderboard #%d", i+1)
+		}
+		boards = append(boards, board)
+	}
+
+	wrHolders := collectWRHolders(boards)
+
+	// Generators are shared between leaderboards using the same template
+	// Each template is parsed once, pages clone it to render in parallel
+	generators := make(map[string]*generator.Generator)
+	getGenerator := func(path string) (*generator.Generator, error) {
+		if gen, ok := generators[path]; ok {
+			return gen, nil
+		}
+		gen, err := newGenerator(config, path, s.storage)
+		if err != nil {
+			return nil, err
+		}
+		generators[path] = gen
+		return gen, nil
+	}
+
+	fmt.Println("\nGenerating pages...")
+	ranking, _ := generator.NewRanking(config.Display) // Validated at startup
+	hubData := &generator.HubData{
+		Title:     config.Hub.Title,
+		WRHolders: wrHolders,
+		HideRanks: ranking.Hide,
+	}
+	if hubData.Title == "" {
+		hubData.Title = defaultHubTitle
+	}
+	if config.Hub.Search {
+		hubData.Search = generator.NewSearchIndex()
+	}
+	// An exhibition turns the hub page into its landing page
+	hubData.Event = newExhibition(config)
+	featured := newExhibitionFeatured(config.Exhibition.Players)
+
+	// Load generators and snapshots serially, then render and minify the pages in parallel
+	pages := make([]batchPage, len(boards))
+	for i, board := range boards {
+		pages[i].gen, pages[i].err = getGenerator(board.template)
+		if pages[i].err == nil {
+			boardConfig := config
+			boardConfig.Page = board.page
+			pages[i].data = s.boardData(boardConfig, board.result, wrHolders)
+		}
+	}
+	renderPages(ctx, boards, pages, renderWorkers(config))
+	s.checkClaims(ctx, config.TimeFormat)
+	s.notifier.send(ctx)
+
+	manifest := export.NewManifest(hubData.Title, s.clock.Now())
+	var rendered []batchBoard
+	for i, board := range boards {
+		if err := pages[i].err; err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: %v\n", board.result.Game.Names.International, board.result.Category.Name, err)
+			fail(exitCode(err))
+			continue
+		}
+		s.pages++
+		rendered = append(rendered, board)
+		if err := s.writeBoardJSON(config, board.result, board.output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: %v\n", board.result.Game.Names.International, board.result.Category.Name, err)
+			fail(exitCode(err))
+		} else if err := s.writeHighlights(pages[i].gen, config, board.result, board.output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: %v\n", board.result.Game.Names.International, board.result.Category.Name, err)
+			fail(exitCode(err))
+		} else if err := s.writePodium(pages[i].gen, config, hubData.Event, board.result, board.output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: %v\n", board.result.Game.Names.International, board.result.Category.Name, err)
+			fail(exitCode(err))
+		}
+
+		hubBoard := generator.HubBoard{
+			Game:        *board.result.Game,
+			Category:    *board.result.Category,
+			Subcategory: board.result.Subcategory,
+			Link:        relativeLink(hubOutput, board.output),
+			Top:         ranking.Apply(topRuns(board.result.Leaderboard.Runs, hubTopRuns), board.result.Leaderboard.Players.M),
+			RunCount:    len(board.result.Leaderboard.Runs),
+			Players:     board.result.Leaderboard.Players.M,
+			Score:       board.result.Score,
+		}
+		if config.Highlights.Enabled {
+			hubBoard.Highlights = relativeLink(hubOutput, highlightsPath(board.output))
+		}
+		if hubData.Event != nil {
+			hubBoard.Podium = relativeLink(hubOutput, podiumPath(board.output))
+		}
+		hubData.Boards = append(hubData.Boards, hubBoard)
+		ranked := ranking.Apply(board.result.Leaderboard.Runs, board.result.Leaderboard.Players.M)
+		if hubData.Search != nil {
+			hubData.Search.Add(hubBoard, ranked)
+		}
+		if hubData.Event != nil {
+			featured.add(hubBoard, ranked)
+		}
+		manifest.Boards = append(manifest.Boards, export.ManifestBoard{
+			Game:        export.NewGame(*board.result.Game),
+			Category:    export.Category{ID: board.result.Category.ID, Name: board.result.Category.Name},
+			Subcategory: board.result.Subcategory,
+			Page:        relativeLink(hubOutput, board.output),
+			JSON:        relativeLink(hubOutput, jsonPath(board.output)),
+			RunCount:    len(board.result.Leaderboard.Runs),
+			Degraded:    export.DegradedRuns(ranked, board.result.Leaderboard.Players.M),
+		})
+	}
+
+	if failed == total {
+		// Nothing was generated, keep the previous hub page and report the failure class itself
+		return withExitCode(failCode, fmt.Errorf("all %d leaderboards failed", total))
+	}
+
+	if hubData.Event != nil {
+		hubData.Event.Featured = featured.list()
+	}
+
+	gen, err := getGenerator("")
+	if err != nil {
+		return err
+	}
+	if config.Hub.Template != "" {
+		if err := gen.SetHubTemplate(config.Hub.Template); err != nil {
+			return withExitCode(exitGeneration, err)
+		}
+	}
+	if err := gen.GenerateHub(hubOutput, hubData); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to generate hub page: %w", err))
+	}
+	fmt.Printf("  ✓ %s (hub)\n", hubOutput)
+	s.pages++
+
+	pageIndex := cache.NewPageIndex(cacheDir)
+	recordPages(pageIndex, rendered)
+	s.retirePages(ctx, gen, pageIndex, rendered, hubOutput, hubData.Title)
+	if err := pageIndex.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if config.Export.JSON {
+		manifestPath := filepath.Join(filepath.Dir(hubOutput), export.ManifestFile)
+		if err := s.writeJSON(manifestPath, manifest); err != nil {
+			return withExitCode(exitGeneration, fmt.Errorf("failed to write manifest: %w", err))
+		}
+		fmt.Printf("  ✓ %s (manifest)\n", manifestPath)
+	}
+	if err := s.writePlayerFiles(); err != nil {
+		return err
+	}
+	if config.Export.Changes {
+		if err := s.writeChanges(config, cacheDir, rendered, hubOutput, failed > 0); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return withExitCode(exitPartialBatch, fmt.Errorf("%d of %d leaderboards failed", failed, total))
+	}
+	return s.staleErr()
+}
+
+// batchPage is the page of a batchBoard being rendered
+type batchPage struct {
+	gen  *generator.Generator
+	data *generator.LeaderboardData
+	err  error // Generator, snapshot or rendering failure
+}
+
+// renderPages renders the pages of the boards with a pool of workers, skipping pages that already failed
+// Rendering and minifying is CPU-bound, the default pool has one worker per CPU
+func renderPages(ctx context.Context, boards []batchBoard, pages []batchPage, workers int) {
+	task := progress.Start(ctx, "Rendering", len(boards))
+	defer task.Finish()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pages[i].err = writeBoard(pages[i].gen, pages[i].data, boards[i].output)
+				task.Add(1)
+				if pages[i].err == nil {
+					task.Fprintf(os.Stdout, "  ✓ %s", boards[i].output)
+				}
+			}
+		}()
+	}
+	for i := range pages {
+		if pages[i].err != nil {
+			task.Add(1)
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// renderWorkers returns the number of pages rendered in parallel
+func renderWorkers(config models.Config) int {
+	if config.Workers > 0 {
+		return config.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// boardOutputs returns the files written for a board page: the page, its JSON export, highlight reel
+// and podium card if enabled
+func boardOutputs(config models.Config, output string) []string {
+	paths := []string{output}
+	if config.Export.JSON {
+		paths = append(paths, jsonPath(output))
+	}
+	if config.Highlights.Enabled {
+		paths = append(paths, highlightsPath(output))
+	}
+	if config.Exhibition.Enabled() {
+		paths = append(paths, podiumPath(output))
+	}
+	return paths
+}
+
+// outputCollision returns the first of paths already in outputs and what writes it, or an empty owner if none is
+func outputCollision(outputs map[string]string, paths []string) (string, string) {
+	for _, path := range paths {
+		if owner, ok := outputs[filepath.Clean(path)]; ok {
+			return path, owner
+		}
+	}
+	return "", ""
+}
+
+// applyDefaults fills the empty fields of a leaderboard entry from the defaults block
+// The template is resolved separately by boardTemplate, as the command line sits between entry and defaults
+func applyDefaults(entry models.LeaderboardConfig, defaults models.LeaderboardDefaults) models.LeaderboardConfig {
+	if entry.Timing == "" {
+		entry.Timing = defaults.Timing
+	}
+	if entry.Top == 0 {
+		entry.Top = defaults.Top
+	}
+	return entry
+}
+
+// boardTemplate returns the template of a leaderboard entry
+// Priority: entry template > --template > defaults template > top-level template
+func boardTemplate(entry models.LeaderboardConfig, cliTemplate string, config models.Config) string {
+	for _, path := range []string{entry.Template, cliTemplate, config.Defaults.Template} {
+		if path != "" {
+			return path
+		}
+	}
+	return config.Template
+}
+
+// batchOutputDir returns the root directory of generated pages in batch mode
+func batchOutputDir(defaults models.LeaderboardDefaults) string {
+	if defaults.OutputDir != "" {
+		return defaults.OutputDir
+	}
+	return filepath.Dir(defaultHubOutput)
+}
+
+// collectWRHolders maps each player holding a #1 place to the leaderboards they lead
+func collectWRHolders(boards []batchBoard) map[string][]string {
+	holders := make(map[string][]string)
+	for _, board := range boards {
+		if board.result.Key.PerCountry > 0 {
+			continue // The #1 of a per-country board is the world record of its full board, or not one at all
+		}
+		label := board.result.Game.Names.International + " " + board.result.Category.Name
+		if board.result.Subcategory != "" {
+			label += " (" + board.result.Subcategory + ")"
+		}
+		for _, run := range board.result.Leaderboard.Runs {
+			if run.Place != 1 {
+				continue
+			}
+			for _, p := range run.Run.Players {
+				holders[p.Key()] = append(holders[p.Key()], label)
+			}
+		}
+	}
+	return holders
+}
+
+// topRuns returns the runs placed within the top n
+func topRuns(runs []models.RunEntry, n int) []models.RunEntry {
+	var top []models.RunEntry
+	for _, run := range runs {
+		if run.Place > 0 && run.Place <= n {
+			top = append(top, run)
+		}
+	}
+	return top
+}
+
+// relativeLink returns the link from the hub page to a leaderboard page
+func relativeLink(hubOutput, boardOutput string) string {
+	rel, err := filepath.Rel(filepath.Dir(hubOutput), boardOutput)
+	if err != nil {
+		rel = boardOutput
+	}
+	return filepath.ToSlash(rel)
+}
+
+// boardSlug builds a file name from game, category, subcategory and non-default timing and top,
+// e.g. "sms-any-gcn" or "sms-any-gcn-ingame-top50"
+func boardSlug(board *boardResult) string {
+	name := board.Game.Abbreviation
+	if name == "" {
+		name = board.Game.Names.International
+	}
+	parts := []string{name, board.Category.Name}
+	if board.Subcategory != "" {
+		parts = append(parts, board.Subcategory)
+	}
+	if board.Key.Timing != "" {
+		parts = append(parts, board.Key.Timing)
+	}
+	if board.Key.Top > 0 && board.Key.Top != api.DefaultTop {
+		parts = append(parts, fmt.Sprintf("top%d", board.Key.Top))
+	}
+	return slugify(strings.Join(parts, "-"))
+}
+
+// slugify lowercases a string and replaces everything except letters and digits with "-"
+func slugify(s string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/export"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// writeChanges compares the boards rendered by a batch with the previous batch and writes changes.json
+// next to the hub page (export.changes), then records them as the baseline of the next batch
+// Boards missing from a batch with failures may have failed to fetch: they are kept in the baseline
+// and not reported as removed.
+func (s *session) writeChanges(config models.Config, cacheDir string, rendered []batchBoard, hubOutput string, partial bool) error {
+	prev, err := cache.LoadBatchState(cacheDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, every board is reported as added\n", err)
+	}
+
+	now := s.clock.Now()
+	changes := export.NewChanges(now)
+	state := &cache.BatchState{GeneratedAt: changes.GeneratedAt, Boards: make(map[string][]string, len(rendered))}
+	if prev != nil {
+		since := prev.GeneratedAt
+		changes.Since = &since
+	}
+	for _, board := range rendered {
+		page := relativeLink(hubOutput, board.output)
+		runs := board.result.Leaderboard.Runs
+		ids := make([]string, len(runs))
+		for i, run := range runs {
+			ids[i] = run.Run.ID
+		}
+		state.Boards[page] = ids
+
+		var prevIDs []string
+		found := false
+		if prev != nil {
+			prevIDs, found = prev.Boards[page]
+		}
+		switch {
+		case !found:
+			changes.Added = append(changes.Added, page)
+			continue
+		case slices.Equal(ids, prevIDs):
+			continue
+		}
+		changes.Updated = append(changes.Updated, page)
+		doc := s.boardDoc(config, board.result)
+		for _, run := range doc.Runs {
+			if !slices.Contains(prevIDs, run.ID) {
+				changes.Runs = append(changes.Runs, export.ChangedRun{Page: page, Run: run})
+			}
+		}
+	}
+	if prev != nil {
+		for page, ids := range prev.Boards {
+			if _, ok := state.Boards[page]; ok {
+				continue
+			}
+			if partial {
+				state.Boards[page] = ids
+			} else {
+				changes.Removed = append(changes.Removed, page)
+			}
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Updated)
+	sort.Strings(changes.Removed)
+	changes.Changed = len(changes.Added)+len(changes.Updated)+len(changes.Removed) > 0
+
+	changesPath := filepath.Join(filepath.Dir(hubOutput), export.ChangesFile)
+	if err := s.writeJSON(changesPath, changes); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to write changes: %w", err))
+	}
+	fmt.Printf("  ✓ %s (%d added, %d updated, %d removed)\n", changesPath, len(changes.Added), len(changes.Updated), len(changes.Removed))
+	if err := cache.SaveBatchState(cacheDir, state); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
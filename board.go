@@ -0,0 +1,971 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/api"
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/diff"
+	"github.com/soar/sr_exhibit/export"
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/progress"
+	"github.com/soar/sr_exhibit/storage"
+)
+
+// boardSpec identifies a leaderboard to generate
+type boardSpec struct {
+	Game        string
+	Category    string
+	Subcategory string            // Value-based subcategory, takes priority over Variables
+	Variables   map[string]string // ID-based variable filters
+	Timing      string            // Timing method, empty for the game's primary timing
+	Top         int               // Number of places to fetch, 0 for the default
+	Merge       []string          // Subcategory values merged into one board, see fetchMerged
+	Scoring     string            // Scoring mode, empty to detect it from the category
+	PerCountry  int               // Best runs of each country kept, see topPerCountry; 0 for the full board
+}
+
+// boardResult holds the fetched data of one leaderboard
+type boardResult struct {
+	Key         *cache.CacheKey
+	Game        *models.Game
+	Category    *models.Category
+	Leaderboard *models.LeaderboardData
+	Subcategory string // Selected subcategory labels, empty if none
+	FromCache   bool
+	CachedAt    time.Time         // When the cached data was fetched, set if FromCache
+	Fallback    bool              // Cached data was used because the live fetch failed
+	Sources     map[string]string // Merged boards: subcategory each run comes from, keyed by run ID
+	Score       bool              // Values are scores, not times
+}
+
+// session holds state shared by all leaderboards generated in one invocation
+type session struct {
+	client       *api.Client
+	playerCache  *cache.PlayerCache
+	lbCache      *cache.LeaderboardCache
+	snapshots    *cache.SnapshotStore
+	useCache     bool
+	refreshCache bool
+	batch        bool                          // Batch mode never prompts, defaults are used instead
+	aliases      map[string]string             // Player ID or name -> display name
+	retries      int                           // Retries of a failed leaderboard fetch
+	fallback     string                        // Cache fallback mode after a failed fetch, see models.CacheConfig
+	fellBack     int                           // Number of boards generated from cached data after a failed fetch
+	storage      storage.Storage               // Where pages and JSON exports are written, shared with the generators
+	clock        clock.Clock                   // Time of snapshots, cache entries and staleness checks
+	notifier     *notifier                     // Notification rules evaluated against the changes of every board
+	verifyClaims bool                          // Cross-check new world records before announcing them (claims.verify)
+	claims       []claim                       // New world records waiting for checkClaims
+	playerFiles  map[string]*export.PlayerFile // Player files (export.players) keyed by path, see addPlayerRuns
+	pages        int                           // Pages generated, for the usage stats
+	failed       int                           // Batch mode: leaderboards that failed, for the usage stats
+}
+
+// interactive reports whether the session may prompt the user
+func (s *session) interactive() bool {
+	return !s.batch && isInteractive()
+}
+
+// newSession creates the API client and initializes the player cache
+// clk is the time source of the session and its caches, see session.clock
+func newSession(config models.Config, timeout time.Duration, clk clock.Clock, cacheDir string, lbCache *cache.LeaderboardCache, snapshots *cache.SnapshotStore, useCache, refreshCache bool) *session {
+	s := &session{
+		client:       api.NewClient(config.API.BaseURL, timeout),
+		lbCache:      lbCache,
+		snapshots:    snapshots,
+		useCache:     useCache,
+		refreshCache: refreshCache,
+		aliases:      config.Aliases,
+		retries:      defaultRetries,
+		fallback:     config.Cache.Fallback,
+		verifyClaims: config.Claims.Verify,
+		clock:        clk,
+	}
+	st, _ := storage.New(config.Storage)         // Validated at startup
+	s.notifier, _ = newNotifier(config, timeout) // Validated at startup
+	s.notifier.clock = s.clock
+	s.storage = storage.NewGuard(st)
+	if config.API.Retries != nil {
+		s.retries = *config.API.Retries
+	}
+	if s.fallback == "" {
+		s.fallback = fallbackOn
+	}
+
+	// Initialize player cache
+	playerCache, err := cache.NewPlayerCache(cacheDir, cache.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize cache, caching disabled: %v\n", err)
+	} else {
+		playerCache.SetClock(s.clock)
+		s.playerCache = playerCache
+		s.client.SetPlayerCache(playerCache)
+		if removed := playerCache.CleanExpired(); removed > 0 {
+			fmt.Printf("Cache: Cleaned %d expired entries\n", removed)
+		}
+		if total, expired := playerCache.Stats(); total > 0 {
+			fmt.Printf("Cache: %d entries (%d expired)\n", total, expired)
+		}
+		metadata := cache.NewMetadataCache(cacheDir)
+		metadata.SetClock(s.clock)
+		s.client.SetMetadataCache(metadata)
+	}
+
+	return s
+}
+
+// newGenerator creates a generator configured with the display options of the config, writing to st
+func newGenerator(config models.Config, templatePath string, st storage.Storage) (*generator.Generator, error) {
+	gen, err := generator.NewGenerator(templatePath, config.CountryCodeMap)
+	if err != nil {
+		return nil, withExitCode(exitGeneration, fmt.Errorf("failed to create generator: %w", err))
+	}
+	if err := gen.SetTimeFormat(config.TimeFormat); err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+	assets := generator.NewAssets(config.AssetsDir)
+	if err := gen.SetAssets(assets, config.Display.Theme, config.Display.Locale); err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+	if err := gen.SetFlagMode(config.Display.Flags); err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+	if err := gen.SetMinify(config.Minify); err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+	ranking, _ := generator.NewRanking(config.Display) // Validated at startup
+	gen.SetCollation(ranking.Collation)
+	gen.SetStorage(st)
+	return gen, nil
+}
+
+// Cache fallback modes (cache.fallback)
+const (
+	fallbackOn    = "on"    // Use cached data when the live fetch fails
+	fallbackStale = "stale" // Same, but exit with exitStale so schedulers can alert
+	fallbackOff   = "off"   // Fail when the live fetch fails
+)
+
+const (
+	// defaultRetries is the number of retries of a failed leaderboard fetch
+	defaultRetries = 2
+	// retryDelay is the wait before the first retry, doubled for each further retry
+	retryDelay = 5 * time.Second
+)
+
+// validateFallback checks the cache fallback options of the config
+func validateFallback(config models.Config) error {
+	switch config.Cache.Fallback {
+	case "", fallbackOn, fallbackStale, fallbackOff:
+	default:
+		return fmt.Errorf("unknown cache.fallback %q (use %s, %s or %s)", config.Cache.Fallback, fallbackOn, fallbackStale, fallbackOff)
+	}
+	if config.API.Retries != nil && *config.API.Retries < 0 {
+		return fmt.Errorf("api.retries must not be negative")
+	}
+	return nil
+}
+
+// staleErr reports boards generated from cached data after a failed fetch, when cache.fallback is "stale"
+func (s *session) staleErr() error {
+	if s.fellBack == 0 || s.fallback != fallbackStale {
+		return nil
+	}
+	return withExitCode(exitStale, fmt.Errorf("live data unavailable, %d page(s) generated from cached data", s.fellBack))
+}
+
+// defaultStaleAfter is the age of cached data after which pages show a "Standings as of" banner
+const defaultStaleAfter = 24 * time.Hour
+
+// staleThreshold returns the configured display.staleAfter, 0 if the banner is disabled
+func staleThreshold(config models.Config) (time.Duration, error) {
+	if config.Display.StaleAfter == "" {
+		return defaultStaleAfter, nil
+	}
+	d, err := time.ParseDuration(config.Display.StaleAfter)
+	if err != nil {
+		return 0, fmt.Errorf("invalid display.staleAfter: %w", err)
+	}
+	return d, nil
+}
+
+// fetchBoard resolves game, category and subcategories, then loads the leaderboard from cache or API
+func (s *session) fetchBoard(ctx context.Context, spec boardSpec) (*boardResult, error) {
+	client := s.client
+
+	if !models.ValidTiming(spec.Timing) {
+		return nil, withExitCode(exitConfig, fmt.Errorf("unknown timing method: %s (use realtime, realtime_noloads or ingame)", spec.Timing))
+	}
+	if !models.ValidScoring(spec.Scoring) {
+		return nil, withExitCode(exitConfig, fmt.Errorf("unknown scoring: %s (use %s or %s)", spec.Scoring, models.ScoringTime, models.ScoringScore))
+	}
+
+	if spec.PerCountry < 0 {
+		return nil, withExitCode(exitConfig, fmt.Errorf("invalid perCountry: %d (use a positive number, or 0 for the full board)", spec.PerCountry))
+	}
+
+	if spec.PerCountry > 0 {
+		full := spec
+		full.PerCountry = 0
+		board, err := s.fetchBoard(ctx, full)
+		if err != nil {
+			return nil, err
+		}
+		return topPerCountry(board, spec.PerCountry), nil
+	}
+	if len(spec.Merge) > 0 {
+		return s.fetchMerged(ctx, spec)
+	}
+	if spec.Category == allCategories {
+		return nil, withExitCode(exitConfig, fmt.Errorf("category \"%s\" is only supported in batch mode", allCategories))
+	}
+
+	fmt.Printf("Searching game: %s\n", spec.Game)
+	game, err := client.SearchGameByName(ctx, spec.Game)
+	if err != nil {
+		return nil, withExitCode(apiExitCode(err), fmt.Errorf("failed to search game: %w", err))
+	}
+	fmt.Printf("  Found game: %s (ID: %s)\n", game.Names.International, game.ID)
+
+	var category *models.Category
+	if spec.Category != "" {
+		fmt.Printf("Getting category: %s\n", spec.Category)
+		cat, err := client.GetCategoryByName(ctx, game.ID, spec.Category)
+		if err != nil {
+			return nil, withExitCode(apiExitCode(err), fmt.Errorf("failed to get category: %w", err))
+		}
+		category = cat
+		fmt.Printf("  Found category: %s (ID: %s)\n", category.Name, category.ID)
+	} else {
+		fmt.Println("Getting game categories...")
+		categories, err := client.GetCategories(ctx, game.ID)
+		if err != nil {
+			return nil, withExitCode(exitAPI, fmt.Errorf("failed to get categories: %w", err))
+		}
+		fmt.Printf("  Found %d categories\n", len(categories))
+
+		cat, err := api.SelectCategory(categories)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select category: %w", err)
+		}
+		category = cat
+	}
+
+	selectedVars, subcategoryLabel, err := s.resolveVariables(ctx, game, category, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create cache key
+	cacheKey := &cache.CacheKey{
+		GameID:       game.ID,
+		GameName:     game.Names.International,
+		CategoryID:   category.ID,
+		CategoryName: category.Name,
+		Variables:    selectedVars,
+		Timing:       spec.Timing,
+		Top:          spec.Top,
+	}
+
+	result := &boardResult{
+		Key:         cacheKey,
+		Game:        game,
+		Category:    category,
+		Subcategory: subcategoryLabel,
+		Score:       isScore(spec.Scoring, category),
+	}
+
+	// Check if using cache
+	if s.refreshCache {
+		// Force refresh
+		fmt.Println("Force refresh mode: Fetching latest data...")
+		if err := s.fetchLiveOrCached(ctx, result); err != nil {
+			return nil, err
+		}
+	} else if s.useCache {
+		// Force use cache
+		if !s.lbCache.Exists(cacheKey) {
+			return nil, withExitCode(exitCacheOnly, fmt.Errorf("cache does not exist, please run once to create cache"))
+		}
+		fmt.Println("Use cache mode: Loading cached data...")
+		result.Leaderboard, result.CachedAt, err = s.loadCached(ctx, cacheKey, true)
+		if err != nil {
+			return nil, err
+		}
+		result.FromCache = true
+		// The fetch time, the file time changes when the cache is upgraded
+		fmt.Printf("✓ Loaded cache (cache time: %s)\n", result.CachedAt.Local().Format("2006-01-02 15:04:05"))
+	} else if s.lbCache.Exists(cacheKey) && s.interactive() {
+		// Auto mode: check cache and prompt
+		cacheTime, _ := s.lbCache.GetCacheTime(cacheKey)
+		fmt.Printf("\nFound local cache (cache time: %s)\n", cacheTime.Format("2006-01-02 15:04:05"))
+		if confirm("Use cached data?") {
+			result.Leaderboard, result.CachedAt, err = s.loadCached(ctx, cacheKey, true)
+			if err != nil {
+				return nil, err
+			}
+			result.FromCache = true
+			fmt.Println("✓ Using cached data")
+		} else {
+			fmt.Println("Fetching latest data...")
+			if err := s.fetchLiveOrCached(ctx, result); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		// No cache or no stdin, fetch directly
+		fmt.Println("Fetching leaderboard data...")
+		if err := s.fetchLiveOrCached(ctx, result); err != nil {
+			return nil, err
+		}
+	}
+
+	applyAliases(result.Leaderboard, s.aliases)
+	fmt.Printf("  Got %d records\n", len(result.Leaderboard.Runs))
+	return result, nil
+}
+
+// applyAliases replaces player display names using the config alias map
+// Keys are player IDs or names (case-insensitive); guests are matched by name
+// Applied after caching, so the cache keeps the speedrun.com names
+func applyAliases(leaderboard *models.LeaderboardData, aliases map[string]string) {
+	if len(aliases) == 0 {
+		return
+	}
+	byName := make(map[string]string, len(aliases))
+	for key, name := range aliases {
+		byName[strings.ToLower(key)] = name
+	}
+	lookup := func(id, name string) (string, bool) {
+		if alias, ok := aliases[id]; ok && id != "" {
+			return alias, true
+		}
+		alias, ok := byName[strings.ToLower(name)]
+		return alias, ok && name != ""
+	}
+
+	for id, pd := range leaderboard.Players.M {
+		name := pd.Names.International
+		if name == "" {
+			name = pd.Name
+		}
+		if alias, ok := lookup(id, name); ok {
+			pd.Names.International = alias
+			leaderboard.Players.M[id] = pd
+		}
+	}
+	for i := range leaderboard.Runs {
+		players := leaderboard.Runs[i].Run.Players
+		for j, p := range players {
+			if p.Rel == "user" {
+				continue
+			}
+			if alias, ok := lookup("", p.Name); ok {
+				players[j].Name = alias
+			}
+		}
+	}
+}
+
+// scorePattern matches the names of score-based categories
+var scorePattern = regexp.MustCompile(`(?i)\b(high ?scores?|scores?|points)\b`)
+
+// isScore reports whether the values of a board are scores
+// The API has no flag for score-based categories, so without a configured scoring mode
+// a category named after scores or points is taken as one
+// The rules are not checked: rules of time categories often mention scores or points
+func isScore(scoring string, category *models.Category) bool {
+	if scoring != "" {
+		return scoring == models.ScoringScore
+	}
+	return scorePattern.MatchString(category.Name)
+}
+
+// resolveVariables determines the variable filters to use for a leaderboard
+// Returns the variable filters and the labels of the selected subcategory values
+func (s *session) resolveVariables(ctx context.Context, game *models.Game, category *models.Category, spec boardSpec) (map[string]string, string, error) {
+	client := s.client
+
+	// Get game variables
+	variables, err := client.GetVariables(ctx, game.ID)
+	if err != nil {
+		return nil, "", withExitCode(exitAPI, fmt.Errorf("failed to get variables: %w", err))
+	}
+
+	// Count subcategory variables for this category
+	var subcategoryVars []models.Variable
+	for _, v := range variables {
+		if v.IsSubcategory && (v.Category == "" || v.Category == category.ID) {
+			subcategoryVars = append(subcategoryVars, v)
+		}
+	}
+	hasSubcategories := len(subcategoryVars) > 0
+
+	// Determine variable filters to use
+	selectedVars := make(map[string]string)
+	subcategoryValue := spec.Subcategory
+
+	if subcategoryValue != "" {
+		// Priority 1: Subcategory value (auto-match "Subcategory" variable)
+		fmt.Printf("Resolving subcategory: %s\n", subcategoryValue)
+		resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, subcategoryValue)
+		if err != nil {
+			return nil, "", withExitCode(apiExitCode(err), fmt.Errorf("failed to resolve subcategory: %w", err))
+		}
+		selectedVars = resolved
+		fmt.Printf("  Resolved to: %v\n", selectedVars)
+	} else if len(spec.Variables) > 0 {
+		// Priority 2: ID-based variables
+		selectedVars = spec.Variables
+		fmt.Printf("Using specified variables: %v\n", selectedVars)
+	} else if hasSubcategories {
+		// Priority 3: Interactive selection or defaults
+		if s.interactive() {
+			fmt.Println("\nDetected subcategory options...")
+			selectedVars = api.SelectSubcategories(variables, category.ID)
+			// If only one subcategory variable, resolve it via subcategory field
+			if len(subcategoryVars) == 1 && len(selectedVars) > 0 {
+				// Extract value label and use subcategory resolution
+				for varID, valID := range selectedVars {
+					for _, v := range subcategoryVars {
+						if v.ID == varID {
+							if val, ok := v.Values.Values[valID]; ok {
+								subcategoryValue = val.Label
+								fmt.Printf("Resolving single subcategory variable: %s = %s\n", v.Name, val.Label)
+							}
+							break
+						}
+					}
+					if subcategoryValue != "" {
+						break
+					}
+				}
+				// Resolve subcategory by value
+				resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, subcategoryValue)
+				if err != nil {
+					return nil, "", withExitCode(apiExitCode(err), fmt.Errorf("failed to resolve subcategory: %w", err))
+				}
+				selectedVars = resolved
+				fmt.Printf("  Resolved to: %v\n", selectedVars)
+			}
+		} else {
+			// Non-interactive: use defaults
+			for _, v := range variables {
+				if v.IsSubcategory && (v.Category == "" || v.Category == category.ID) && v.Values.Default != "" {
+					selectedVars[v.ID] = v.Values.Default
+				}
+			}
+			if len(selectedVars) > 0 {
+				fmt.Printf("Using default subcategory values\n")
+			}
+			// If only one subcategory variable, also resolve via subcategory field for consistency
+			if len(subcategoryVars) == 1 && len(selectedVars) > 0 {
+				for varID, valID := range selectedVars {
+					for _, v := range subcategoryVars {
+						if v.ID == varID {
+							if val, ok := v.Values.Values[valID]; ok {
+								subcategoryValue = val.Label
+								// Re-resolve via subcategory field
+								resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, subcategoryValue)
+								if err != nil {
+									return nil, "", withExitCode(apiExitCode(err), fmt.Errorf("failed to resolve subcategory: %w", err))
+								}
+								selectedVars = resolved
+							}
+							break
+						}
+					}
+					break
+				}
+			}
+		}
+	}
+
+	return selectedVars, subcategoryLabels(subcategoryVars, selectedVars), nil
+}
+
+// subcategoryLabels returns the labels of the selected subcategory values, joined by ", "
+func subcategoryLabels(subcategoryVars []models.Variable, selectedVars map[string]string) string {
+	var labels []string
+	for _, v := range subcategoryVars {
+		if valID, ok := selectedVars[v.ID]; ok {
+			if val, ok := v.Values.Values[valID]; ok {
+				labels = append(labels, val.Label)
+			}
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// fetchLiveOrCached fetches the leaderboard from the API
+// When the fetch fails and a cache entry exists, the cached data is used instead unless cache.fallback is "off"
+func (s *session) fetchLiveOrCached(ctx context.Context, result *boardResult) error {
+	leaderboard, err := s.fetchLive(ctx, result)
+	if err == nil {
+		result.Leaderboard = leaderboard
+		return nil
+	}
+	// Only API failures fall back, not e.g. an interrupted run
+	if code := exitCode(err); code != exitAPI && code != exitRateLimited {
+		return err
+	}
+	if s.fallback == fallbackOff || !s.lbCache.Exists(result.Key) {
+		return err
+	}
+
+	cached, cachedAt, cacheErr := s.loadCached(ctx, result.Key, false) // The API just failed
+	if cacheErr != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\n!!! WARNING: %v\n", err)
+	fmt.Fprintf(os.Stderr, "!!! Using cached data from %s, the page does not show the current standings\n\n", cachedAt.Format("2006-01-02 15:04:05"))
+	result.Leaderboard = cached
+	result.CachedAt = cachedAt
+	result.FromCache = true
+	result.Fallback = true
+	s.fellBack++
+	return nil
+}
+
+// fetchLive fetches the leaderboard from the API and updates the cache
+// A failed fetch is retried s.retries times with increasing delays
+func (s *session) fetchLive(ctx context.Context, result *boardResult) (*models.LeaderboardData, error) {
+	var leaderboard *models.LeaderboardData
+	var err error
+	for attempt := 0; ; attempt++ {
+		leaderboard, err = s.client.GetLeaderboard(ctx, result.Game.ID, result.Category.ID, result.Key.Variables, api.LeaderboardOptions{
+			Timing: result.Key.Timing,
+			Top:    result.Key.Top,
+		})
+		if err == nil || attempt >= s.retries || ctx.Err() != nil || !api.Retryable(err) {
+			break
+		}
+		delay := retryDelay << attempt
+		var limited *api.ErrRateLimited
+		if errors.As(err, &limited) && limited.RetryAfter > delay {
+			delay = limited.RetryAfter
+		}
+		fmt.Fprintf(os.Stderr, "Warning: Failed to get leaderboard: %v, retrying in %s\n", err, delay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+	if err != nil {
+		return nil, withExitCode(exitAPI, fmt.Errorf("failed to get leaderboard: %w", err))
+	}
+	// Save cache
+	if err := saveToCache(s.lbCache, result.Key, result.Game, result.Category, leaderboard, s.playerCache, s.clock.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
+	} else {
+		fmt.Println("✓ Data cached")
+	}
+	return leaderboard, nil
+}
+
+// loadCached loads the leaderboard from the CSV cache and fills in player data
+// Also returns when the cached data was fetched
+// upgrade fetches the fields missing from caches written by earlier versions, see upgradeCached
+func (s *session) loadCached(ctx context.Context, cacheKey *cache.CacheKey, upgrade bool) (*models.LeaderboardData, time.Time, error) {
+	cachedData, err := s.lbCache.Load(cacheKey)
+	if err != nil {
+		return nil, time.Time{}, withExitCode(exitCacheOnly, fmt.Errorf("failed to load cache: %w", err))
+	}
+	if cachedData.CachedAt.IsZero() {
+		// Caches written before #CACHED_AT was recorded
+		cachedData.CachedAt, _ = s.lbCache.GetCacheTime(cacheKey)
+	}
+	cachedAt := cachedData.CachedAt
+	if upgrade && len(cachedData.Incomplete) > 0 {
+		s.upgradeCached(ctx, cachedData)
+	}
+
+	// Collect all player IDs that need to be fetched
+	playerIDs := make(map[string]bool)
+	for _, run := range cachedData.Runs {
+		for _, p := range run.Run.Players {
+			if p.Rel == "user" {
+				playerIDs[p.ID] = true
+			}
+		}
+	}
+
+	// Fill player data: first from cache, then from API if cache miss
+	// Preserve existing Players from leaderboard cache (contains country_code)
+	// CSV cache (country_code) takes priority over playerCache (JSON)
+	leaderboardPlayers := cachedData.Players
+	cachedData.Players = make(map[string]models.PlayerData)
+
+	task := progress.Start(ctx, "Loading players", len(playerIDs))
+	for playerID := range playerIDs {
+		task.Add(1)
+		// Start with leaderboard cache data as base (has country_code from CSV)
+		var basePlayer models.PlayerData
+		hasLbData := false
+		if lbPlayer, found := leaderboardPlayers[playerID]; found {
+			basePlayer = lbPlayer
+			hasLbData = true
+		}
+
+		// Try to get full data from playerCache (JSON) for name style etc
+		var data *models.PlayerData
+		if s.playerCache != nil {
+			if cached, found := s.playerCache.Get(playerID); found {
+				data = cached
+			}
+		}
+
+		// Cache miss, fetch from API: the user endpoint has the current name, the CSV one may be stale
+		if data == nil {
+			playerData, err := s.client.GetUser(ctx, playerID)
+			if err == nil {
+				data = playerData
+				// Save to cache
+				if s.playerCache != nil {
+					s.playerCache.Set(playerID, *playerData)
+				}
+			} else {
+				task.Fprintf(os.Stderr, "Warning: Failed to fetch player %s: %v", playerID, err)
+			}
+		}
+
+		if data != nil {
+			if hasLbData {
+				// Always use country_code from leaderboard cache (CSV) as priority
+				if basePlayer.Location != nil && basePlayer.Location.Country != nil {
+					// Use CSV country_code, override playerCache
+					if data.Location == nil {
+						data.Location = &models.Location{}
+					}
+					data.Location.Country = basePlayer.Location.Country
+				}
+				if old := basePlayer.Names.International; old != "" && data.Names.International != "" && old != data.Names.International {
+					task.Fprintf(os.Stdout, "  Player renamed: %s -> %s", old, data.Names.International)
+				}
+			}
+			cachedData.Players[playerID] = *data
+			continue
+		}
+
+		// API unavailable, use leaderboard cache data (name and country_code at cache time)
+		if hasLbData {
+			cachedData.Players[playerID] = basePlayer
+		}
+	}
+	task.Finish()
+
+	// Save cache to file
+	if s.playerCache != nil {
+		if err := s.playerCache.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
+		}
+	}
+
+	return &models.LeaderboardData{
+		Game:      cachedData.Game.ID,
+		Category:  cachedData.Category.ID,
+		Weblink:   cachedData.Weblink,
+		Runs:      cachedData.Runs,
+		Players:   models.PlayersField{M: cachedData.Players},
+		Platforms: models.PlatformsField{M: cachedData.Platforms},
+	}, cachedAt, nil
+}
+
+// upgradeCached fetches the run fields a leaderboard cached by an earlier version lacks (platform, weblink
+// and comment) and the missing platform names, then saves the upgraded cache, so pages rendered from it
+// don't show blank columns. The standings stay those of the cache
+// A failed fetch leaves the rest for the next load: the page shows what the cache has
+func (s *session) upgradeCached(ctx context.Context, cached *cache.CachedLeaderboard) {
+	incomplete := make(map[string]bool, len(cached.Incomplete))
+	for _, id := range cached.Incomplete {
+		incomplete[id] = true
+	}
+	fmt.Printf("Cache written by an earlier version, fetching the platform, link and comment of %d run(s)...\n", len(incomplete))
+
+	var failed error
+	task := progress.Start(ctx, "Upgrading cache", len(incomplete))
+	for i := range cached.Runs {
+		run := &cached.Runs[i].Run
+		if !incomplete[run.ID] {
+			continue
+		}
+		details, err := s.client.GetRunDetails(ctx, run.ID)
+		if err != nil && !errors.Is(err, api.ErrNotFound) { // Runs deleted upstream have nothing to fetch
+			failed = fmt.Errorf("run %s: %w", run.ID, err)
+			break
+		}
+		if details != nil {
+			run.Weblink, run.Comment, run.System = details.Weblink, details.Comment, details.System
+		}
+		task.Add(1)
+	}
+	task.Finish()
+
+	for _, entry := range cached.Runs {
+		id := entry.Run.System.Platform
+		if _, ok := cached.Platforms[id]; ok || id == "" || failed != nil {
+			continue
+		}
+		name, err := s.client.GetPlatformName(ctx, id)
+		if err != nil {
+			failed = fmt.Errorf("platform %s: %w", id, err)
+			break
+		}
+		cached.Platforms[id] = name
+	}
+
+	if failed != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to upgrade the cache (%v), the page may lack platforms, links and comments\n", failed)
+		return
+	}
+	cached.Incomplete = nil
+	if err := s.lbCache.Save(cached); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save the upgraded cache: %v\n", err)
+		return
+	}
+	fmt.Println("✓ Cache upgraded")
+}
+
+// renderBoard compares the board against its previous snapshot, records a new snapshot and writes the page
+func (s *session) renderBoard(gen *generator.Generator, config models.Config, board *boardResult, outputPath string, wrHolders map[string][]string) error {
+	return writeBoard(gen, s.boardData(config, board, wrHolders), outputPath)
+}
+
+// boardData compares the board against its previous snapshot, records a new snapshot and returns the page data
+// Snapshots are not safe for concurrent use, callers rendering in parallel call it serially first
+func (s *session) boardData(config models.Config, board *boardResult, wrHolders map[string][]string) *generator.LeaderboardData {
+	data := &generator.LeaderboardData{
+		Game:        *board.Game,
+		Category:    *board.Category,
+		Leaderboard: *board.Leaderboard,
+		Players:     board.Leaderboard.Players.M,
+		WRHolders:   wrHolders,
+		ShowGaps:    config.Display.ShowGaps,
+		VisibleRows: config.Display.VisibleRows,
+		Page:        config.Page,
+		Sources:     board.Sources,
+		Score:       board.Score,
+	}
+	if degraded := export.DegradedRuns(board.Leaderboard.Runs, board.Leaderboard.Players.M); len(degraded) > 0 {
+		var ids []string
+		for _, run := range degraded {
+			ids = append(ids, run.Players...)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s - %s: %d run(s) shown with placeholders, no data for players %s\n",
+			board.Game.Names.International, board.Category.Name, len(degraded), strings.Join(ids, ", "))
+	}
+	data.Events, _ = generator.ParseEvents(config.Events) // Validated at startup
+	ranking, _ := generator.NewRanking(config.Display)    // Validated at startup
+	data.Leaderboard.Runs = ranking.Apply(board.Leaderboard.Runs, board.Leaderboard.Players.M)
+	data.HideRanks = ranking.Hide
+	if len(config.Exhibition.Players) > 0 {
+		data.Featured = featuredRuns(newFeaturedMatcher(config.Exhibition.Players), data.Leaderboard.Runs, data.Players)
+	}
+	if board.FromCache {
+		// Data used after a failed fetch is always flagged, however recent
+		if staleAfter, _ := staleThreshold(config); board.Fallback || staleAfter > 0 && s.clock.Now().Sub(board.CachedAt) > staleAfter {
+			data.DataAsOf = board.CachedAt
+		}
+	}
+
+	// Compare against the previous snapshot, then record this generation
+	prevSnapshot, err := s.snapshots.Latest(board.Key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to load previous snapshot: %v\n", err)
+	}
+	if config.Display.RankMovement {
+		data.Movements = computeMovements(prevSnapshot, board.Leaderboard.Runs)
+	}
+	if !board.FromCache {
+		snap := cache.NewSnapshot(board.Key, board.Subcategory, board.Leaderboard.Runs, board.Leaderboard.Players.M, s.clock.Now())
+		snap.Score = board.Score
+		// A renamed board is recorded too, so the digest shows its current title
+		if !snap.SameStandings(prevSnapshot) || snap.Title != prevSnapshot.Title {
+			if err := s.snapshots.Save(board.Key, snap); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to save snapshot: %v\n", err)
+			}
+			changes := diff.Compare(prevSnapshot, snap)
+			s.notifier.collect(changes, board.Leaderboard.Players.M, board.Score)
+			if s.verifyClaims {
+				for _, c := range changes {
+					if c.Kind == diff.NewWR {
+						s.claims = append(s.claims, claim{board: board, change: c})
+					}
+				}
+			}
+		}
+	}
+
+	// The sparklines end with the snapshot just recorded, or the latest one for cached data
+	if config.Display.Sparkline > 0 {
+		history, err := s.snapshots.Recent(board.Key, config.Display.Sparkline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to load snapshots: %v\n", err)
+		}
+		data.Trajectories = computeTrajectories(history, board.Leaderboard.Runs)
+	}
+	if fade, _ := heatmapFade(config); fade > 0 { // Validated at startup
+		now := s.clock.Now()
+		history, err := s.snapshots.Since(board.Key, now.Add(-fade))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to load snapshots: %v\n", err)
+		}
+		data.Heat = computeHeat(history, board.Leaderboard.Runs, now, fade)
+	}
+
+	return data
+}
+
+// writeBoardJSON writes the JSON export of a board next to its page, if enabled
+// The runs of its players are added to the player files, written by writePlayerFiles
+func (s *session) writeBoardJSON(config models.Config, board *boardResult, outputPath string) error {
+	if !config.Export.JSON && !config.Export.Players {
+		return nil
+	}
+	doc := s.boardDoc(config, board)
+	if config.Export.Players {
+		s.addPlayerRuns(doc, board.Leaderboard.Players.M, outputPath)
+	}
+	if !config.Export.JSON {
+		return nil
+	}
+	if err := s.writeJSON(jsonPath(outputPath), doc); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to write JSON export: %w", err))
+	}
+	return nil
+}
+
+// boardDoc returns the JSON export of a board, places numbered like on the page
+func (s *session) boardDoc(config models.Config, board *boardResult) *export.Board {
+	var dataAsOf time.Time
+	if board.FromCache {
+		dataAsOf = board.CachedAt
+	}
+	ranking, _ := generator.NewRanking(config.Display) // Validated at startup
+	leaderboard := *board.Leaderboard
+	leaderboard.Runs = ranking.Apply(leaderboard.Runs, leaderboard.Players.M)
+	doc := export.NewBoard(*board.Game, *board.Category, board.Subcategory, board.Key.Timing, &leaderboard, config.TimeFormat, s.clock.Now(), dataAsOf)
+	if board.Score {
+		doc.UseScores()
+	}
+	return doc
+}
+
+// addPlayerRuns adds the runs of a board to the files of its players, next to the board page
+// Boards whose pages share a directory share the player files
+func (s *session) addPlayerRuns(doc *export.Board, players map[string]models.PlayerData, outputPath string) {
+	if s.playerFiles == nil {
+		s.playerFiles = make(map[string]*export.PlayerFile)
+	}
+	added := make(map[string]bool)
+	for _, run := range doc.Runs {
+		for _, p := range run.Players {
+			if p.Guest || added[p.ID] {
+				continue
+			}
+			added[p.ID] = true
+			path := filepath.Join(filepath.Dir(outputPath), export.PlayersDir, filepath.Base(p.ID)+".json")
+			file, ok := s.playerFiles[path]
+			if !ok {
+				file = export.NewPlayerFile(p.ID, players[p.ID], doc.GeneratedAt)
+				s.playerFiles[path] = file
+			}
+			file.AddRuns(doc, relativeLink(path, outputPath))
+		}
+	}
+}
+
+// writePlayerFiles writes the player files collected from the boards of the session
+func (s *session) writePlayerFiles() error {
+	paths := make([]string, 0, len(s.playerFiles))
+	for path := range s.playerFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := s.writeJSON(path, s.playerFiles[path]); err != nil {
+			return withExitCode(exitGeneration, fmt.Errorf("failed to write player file: %w", err))
+		}
+	}
+	if len(paths) > 0 {
+		fmt.Printf("  ✓ %d player files\n", len(paths))
+	}
+	return nil
+}
+
+// writeHighlights writes the highlight reel of a board next to its page, if enabled
+func (s *session) writeHighlights(gen *generator.Generator, config models.Config, board *boardResult, outputPath string) error {
+	if !config.Highlights.Enabled {
+		return nil
+	}
+	top, seconds := config.Highlights.Runs, config.Highlights.Seconds
+	if top == 0 {
+		top = generator.DefaultHighlightRuns
+	}
+	if seconds == 0 {
+		seconds = generator.DefaultHighlightSeconds
+	}
+	ranking, _ := generator.NewRanking(config.Display) // Validated at startup
+	data := &generator.HighlightsData{
+		Game:        *board.Game,
+		Category:    *board.Category,
+		Subcategory: board.Subcategory,
+		Runs:        generator.NewHighlights(ranking.Apply(board.Leaderboard.Runs, board.Leaderboard.Players.M), top, s.clock.Now()),
+		Players:     board.Leaderboard.Players.M,
+		Seconds:     seconds,
+		HideRanks:   ranking.Hide,
+		Score:       board.Score,
+	}
+	if err := gen.GenerateHighlights(highlightsPath(outputPath), data); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to generate highlight reel: %w", err))
+	}
+	s.pages++
+	return nil
+}
+
+// highlightsPath returns the path of the highlight reel of a page, e.g. "output/sms-any.html" -> "output/sms-any-highlights.html"
+func highlightsPath(pagePath string) string {
+	ext := filepath.Ext(pagePath)
+	return strings.TrimSuffix(pagePath, ext) + "-highlights" + ext
+}
+
+// writeJSON writes a JSON export document to the output storage
+func (s *session) writeJSON(path string, doc any) error {
+	data, err := export.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	file, err := s.storage.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Commit()
+}
+
+// jsonPath returns the path of the JSON export of a page, e.g. "output/sms-any.html" -> "output/sms-any.json"
+func jsonPath(pagePath string) string {
+	return strings.TrimSuffix(pagePath, filepath.Ext(pagePath)) + ".json"
+}
+
+// writeBoard renders and writes the page of a board, safe for concurrent use
+func writeBoard(gen *generator.Generator, data *generator.LeaderboardData, outputPath string) error {
+	if err := gen.Generate(outputPath, data); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to generate page: %w", err))
+	}
+	return nil
+}
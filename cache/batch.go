@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// batchFileName is the file of the boards generated by the last batch run, the baseline of changes.json
+const batchFileName = "batch.json"
+
+// BatchState records the boards of a batch run, compared with the next one
+type BatchState struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Boards      map[string][]string `json:"boards"` // Page path relative to the hub page -> run IDs, in board order
+}
+
+// LoadBatchState loads the state of the last batch run, nil if there is none
+func LoadBatchState(dir string) (*BatchState, error) {
+	if dir == "" {
+		dir = DefaultCacheDir
+	}
+	data, err := os.ReadFile(filepath.Join(dir, batchFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read batch state: %w", err)
+	}
+	var state BatchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state: %w", err)
+	}
+	if state.Boards == nil {
+		state.Boards = make(map[string][]string)
+	}
+	return &state, nil
+}
+
+// SaveBatchState saves the state of a batch run, the baseline of the next one
+func SaveBatchState(dir string, state *BatchState) error {
+	if dir == "" {
+		dir = DefaultCacheDir
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize batch state: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, batchFileName), data); err != nil {
+		return fmt.Errorf("failed to save batch state: %w", err)
+	}
+	return nil
+}
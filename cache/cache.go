@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/soar/sr_exhibit/clock"
 	"github.com/soar/sr_exhibit/models"
 )
 
@@ -32,7 +33,8 @@ type PlayerCache struct {
 	dir     string
 	ttl     time.Duration
 	players map[string]*PlayerCacheItem
-	dirty   bool // Marks if there are unsaved changes
+	dirty   bool        // Marks if there are unsaved changes
+	clock   clock.Clock // Time source of the expiry checks, see SetClock
 }
 
 // NewPlayerCache creates a new player cache
@@ -53,6 +55,7 @@ func NewPlayerCache(dir string, ttl time.Duration) (*PlayerCache, error) {
 		dir:     dir,
 		ttl:     ttl,
 		players: make(map[string]*PlayerCacheItem),
+		clock:   clock.System,
 	}
 
 	// Load existing cache
@@ -65,6 +68,13 @@ func NewPlayerCache(dir string, ttl time.Duration) (*PlayerCache, error) {
 	return cache, nil
 }
 
+// SetClock replaces the time source of the cache, e.g. with a clock.Fake in tests
+func (c *PlayerCache) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
 // Load loads cache from file
 func (c *PlayerCache) Load() error {
 	c.mu.Lock()
@@ -117,14 +127,7 @@ func (c *PlayerCache) Save() error {
 	}
 
 	// Write to temp file first, then rename (atomic operation)
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write cache file: %w", err)
-	}
-
-	// Rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath) // Clean up temp file
+	if err := writeFileAtomic(path, data); err != nil {
 		return fmt.Errorf("failed to save cache file: %w", err)
 	}
 
@@ -145,7 +148,7 @@ func (c *PlayerCache) Get(playerID string) (*models.PlayerData, bool) {
 	}
 
 	// Check if expired
-	if time.Since(item.CachedAt) > c.ttl {
+	if c.clock.Now().Sub(item.CachedAt) > c.ttl {
 		return nil, false
 	}
 
@@ -159,7 +162,7 @@ func (c *PlayerCache) Set(playerID string, data models.PlayerData) {
 
 	c.players[playerID] = &PlayerCacheItem{
 		Data:     data,
-		CachedAt: time.Now(),
+		CachedAt: c.clock.Now(),
 	}
 	c.dirty = true
 }
@@ -169,7 +172,7 @@ func (c *PlayerCache) CleanExpired() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := time.Now()
+	now := c.clock.Now()
 	removed := 0
 
 	for id, item := range c.players {
@@ -209,7 +212,7 @@ func (c *PlayerCache) Stats() (total, expired int) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	now := time.Now()
+	now := c.clock.Now()
 	for _, item := range c.players {
 		total++
 		if now.Sub(item.CachedAt) > c.ttl {
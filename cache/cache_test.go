@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/models"
+)
+
+func TestPlayerCacheExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	c, err := NewPlayerCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetClock(clk)
+
+	c.Set("u1", models.PlayerData{ID: "u1"})
+	clk.Advance(30 * time.Minute)
+	c.Set("u2", models.PlayerData{ID: "u2"})
+
+	clk.Advance(30 * time.Minute)
+	if _, ok := c.Get("u1"); !ok {
+		t.Error("u1 expired at exactly the TTL")
+	}
+	clk.Advance(time.Second)
+	if _, ok := c.Get("u1"); ok {
+		t.Error("u1 not expired after the TTL")
+	}
+	if _, ok := c.Get("u2"); !ok {
+		t.Error("u2 expired before the TTL")
+	}
+	if total, expired := c.Stats(); total != 2 || expired != 1 {
+		t.Errorf("Stats() = %d, %d, want 2, 1", total, expired)
+	}
+	if removed := c.CleanExpired(); removed != 1 {
+		t.Errorf("CleanExpired() = %d, want 1", removed)
+	}
+
+	// Entries keep their time across a reload
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewPlayerCache(c.dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.SetClock(clk)
+	clk.Advance(30 * time.Minute)
+	if _, ok := reloaded.Get("u2"); ok {
+		t.Error("reloaded u2 not expired after the TTL")
+	}
+}
+
+func TestMetadataCacheTTL(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewMetadataCache(t.TempDir())
+	c.SetClock(clk)
+
+	if err := c.Set("categories:g1", []string{"c1"}); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(CategoryTTL)
+
+	var got []string
+	if !c.Get("categories:g1", CategoryTTL, &got) || len(got) != 1 || got[0] != "c1" {
+		t.Errorf("Get at the TTL = %v, want [c1]", got)
+	}
+	clk.Advance(time.Second)
+	if c.Get("categories:g1", CategoryTTL, &got) {
+		t.Error("entry not expired after the TTL")
+	}
+	if !c.Get("categories:g1", GameTTL, &got) {
+		t.Error("entry expired before a longer TTL")
+	}
+}
+
+func TestLeaderboardIncomplete(t *testing.T) {
+	c := NewLeaderboardCache(t.TempDir())
+	key := &CacheKey{GameID: "g1", CategoryID: "c1"}
+	run := models.RunEntry{Place: 1, Run: models.RunData{
+		ID:      "r1",
+		Players: []models.Player{{Rel: "guest", Name: "Alice"}},
+		Weblink: "https://www.speedrun.com/sms/run/r1",
+		System:  models.RunSystem{Platform: "p1"},
+	}}
+	if err := c.Save(&CachedLeaderboard{Key: *key, Runs: []models.RunEntry{run}, Platforms: map[string]string{"p1": "GameCube"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Load(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Incomplete) != 0 || got.Runs[0].Run.Weblink != run.Run.Weblink || got.Platforms["p1"] != "GameCube" {
+		t.Errorf("Load() = %+v, want the saved run, complete", got)
+	}
+
+	// A version 1 row ends at the video links
+	v1 := "#META,VERSION,1\n#GAME,g1,Game\n#CATEGORY,c1,Any%\n1,,Alice,,60,2025-01-01,,r1,\n"
+	if err := os.WriteFile(c.GetFileName(key), []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = c.Load(key); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Incomplete) != 1 || got.Incomplete[0] != "r1" {
+		t.Errorf("Incomplete = %v, want [r1]", got.Incomplete)
+	}
+}
+
+func TestLockStaleTakeover(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, lockFileName)
+
+	// A lock no longer refreshed is taken over, the renamed file is removed
+	if err := os.WriteFile(path, []byte("1 elsewhere\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(path, old, old)
+	lock, err := AcquireLock(dir, 0)
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("cache directory has %d files, want the lock only", len(entries))
+	}
+
+	// A run that saw the lock abandoned before it was taken over must not remove the new one
+	if removeAbandoned(path) {
+		t.Error("live lock removed")
+	}
+	if pid, _ := readLock(path); pid != os.Getpid() {
+		t.Errorf("lock PID = %d after a failed takeover, want %d", pid, os.Getpid())
+	}
+	if err := lock.Release(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockStaleTakeoverRace(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		dir := t.TempDir()
+		path := filepath.Join(dir, lockFileName)
+		if err := os.WriteFile(path, []byte("1 elsewhere\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-2 * staleLockAge)
+		os.Chtimes(path, old, old)
+
+		// Runs finding the same abandoned lock: exactly one of them may hold the cache directory
+		const runs = 32
+		locks := make(chan *Lock, runs)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < runs; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				lock, err := AcquireLock(dir, 0)
+				if err == nil {
+					locks <- lock
+				} else if !errors.Is(err, ErrLocked) {
+					t.Error(err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(locks)
+		if len(locks) != 1 {
+			t.Fatalf("round %d: %d runs hold the lock, want 1", round, len(locks))
+		}
+		(<-locks).Release()
+	}
+}
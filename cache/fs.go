@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DirEnv is the environment variable overriding the default cache directory
+	DirEnv = "SR_EXHIBIT_CACHE_DIR"
+	// appDirName is the application directory inside the user cache directory
+	appDirName = "sr_exhibit"
+	// lockFileName is the lock file preventing concurrent runs from sharing a cache directory
+	lockFileName = ".lock"
+	// staleLockAge is the age after which a lock file that is no longer refreshed is ignored
+	staleLockAge = 5 * time.Minute
+	// lockRefreshInterval is how often the holder refreshes the lock file's modification time
+	lockRefreshInterval = time.Minute
+	// takeoverSuffix names the file held by the run removing an abandoned lock, e.g. ".lock.takeover"
+	takeoverSuffix = ".takeover"
+	// staleTakeoverAge is the age after which a takeover file is ignored, a takeover lasts milliseconds
+	staleTakeoverAge = 10 * time.Second
+	// renameRetries is the number of attempts to replace a file that is briefly held open
+	// by another process (e.g. antivirus or indexing on Windows)
+	renameRetries = 5
+)
+
+// ResolveDir returns the cache directory to use
+// Priority: configured directory > SR_EXHIBIT_CACHE_DIR > existing ".cache" in the working directory
+// (caches created by older versions) > "sr_exhibit" in the user cache directory
+// (%LocalAppData% on Windows, ~/Library/Caches on macOS, $XDG_CACHE_HOME or ~/.cache on Linux)
+func ResolveDir(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	if info, err := os.Stat(DefaultCacheDir); err == nil && info.IsDir() {
+		return DefaultCacheDir
+	}
+	if userDir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(userDir, appDirName)
+	}
+	return DefaultCacheDir
+}
+
+// safeFileName replaces characters that are not allowed in file names on Windows
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '<', '>', ':', '"', '/', '\\', '|', '?', '*':
+			return '-'
+		}
+		if r < 32 {
+			return '-'
+		}
+		return r
+	}, name)
+}
+
+// writeFileAtomic writes data to a temp file next to path, then replaces path with it
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := replaceFile(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// replaceFile renames src to dst, replacing dst
+// On Windows the rename fails while another process has dst open, so it is retried briefly
+func replaceFile(src, dst string) error {
+	var err error
+	for i := 0; i < renameRetries; i++ {
+		if err = os.Rename(src, dst); err == nil {
+			return nil
+		}
+		time.Sleep(time.Duration(i+1) * 50 * time.Millisecond)
+	}
+	return err
+}
+
+// Lock is an exclusive lock on a cache directory
+type Lock struct {
+	path string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ErrLocked is returned when another run holds the cache directory lock
+var ErrLocked = errors.New("cache directory is locked by another run")
+
+// AcquireLock locks a cache directory, waiting up to timeout for another run to finish
+// The lock is a file created exclusively, which works the same on every platform and on shared drives.
+// It holds the PID and host of its owner: a lock whose owner is no longer running on this host
+// (Ctrl+C, crash) is taken over at once. The owner refreshes the file's modification time,
+// so a lock that isn't refreshed for staleLockAge (e.g. owner on another host died) is ignored.
+func AcquireLock(dir string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	path := filepath.Join(dir, lockFileName)
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d %s\n", os.Getpid(), hostname())
+			f.Close()
+			l := &Lock{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+			go l.refresh()
+			return l, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		// Remove locks left behind by interrupted or crashed runs
+		if lockAbandoned(path) && removeAbandoned(path) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w (%s)", ErrLocked, lockHolder(path))
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// refresh touches the lock file until the lock is released, so other runs don't consider it stale
+func (l *Lock) refresh() {
+	defer close(l.done)
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// lockAbandoned reports whether a lock file was left by a run that is gone
+func lockAbandoned(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if time.Since(info.ModTime()) > staleLockAge {
+		return true
+	}
+	pid, host := readLock(path)
+	return pid > 0 && host == hostname() && !processAlive(pid)
+}
+
+// removeAbandoned removes an abandoned lock file, reporting whether it did
+// Runs that find the same abandoned lock take turns through a takeover file created exclusively,
+// and check the lock again before removing it, so a lock another run created meanwhile is left alone
+func removeAbandoned(path string) bool {
+	takeover := path + takeoverSuffix
+	f, err := os.OpenFile(takeover, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		// Left by a run that stopped during its takeover
+		if info, err := os.Stat(takeover); err == nil && time.Since(info.ModTime()) > staleTakeoverAge {
+			os.Remove(takeover)
+		}
+		return false
+	}
+	f.Close()
+	defer os.Remove(takeover)
+
+	if !lockAbandoned(path) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// readLock returns the PID and host written to a lock file, 0 if unreadable
+func readLock(path string) (int, string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, ""
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, ""
+	}
+	host := ""
+	if len(fields) > 1 {
+		host = fields[1]
+	}
+	return pid, host
+}
+
+// hostname returns the host name written to lock files
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// lockHolder describes the process holding a lock file
+func lockHolder(path string) string {
+	pid, host := readLock(path)
+	if pid == 0 {
+		return path
+	}
+	if host != "" && host != hostname() {
+		return fmt.Sprintf("pid %d on %s, remove %s if that run is gone", pid, host, path)
+	}
+	return fmt.Sprintf("pid %d, remove %s if that run is gone", pid, path)
+}
+
+// Release stops refreshing the lock and removes it
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	return os.Remove(l.path)
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// knownValuesFileName is the file of the subcategory values seen by earlier runs
+const knownValuesFileName = "known_values.json"
+
+// KnownValues remembers the values of each subcategory variable seen by earlier runs,
+// so values added on speedrun.com can be reported
+// Keys identify a variable of a category, e.g. "<game ID>/<category ID>/<variable ID>"
+type KnownValues struct {
+	dir    string
+	values map[string][]string // Key -> sorted value IDs
+	dirty  bool
+}
+
+// NewKnownValues loads the known values, a missing or unreadable file starts empty
+func NewKnownValues(dir string) *KnownValues {
+	if dir == "" {
+		dir = DefaultCacheDir
+	}
+	k := &KnownValues{dir: dir, values: make(map[string][]string)}
+	data, err := os.ReadFile(k.filePath())
+	if err != nil {
+		return k
+	}
+	if err := json.Unmarshal(data, &k.values); err != nil || k.values == nil {
+		k.values = make(map[string][]string)
+	}
+	return k
+}
+
+// Update records the current values of key and returns the ones not seen before
+// Returns nil the first time a key is seen, when every value is new
+func (k *KnownValues) Update(key string, values []string) []string {
+	current := append([]string(nil), values...)
+	sort.Strings(current)
+	known, seen := k.values[key]
+
+	var added []string
+	if seen {
+		for _, v := range current {
+			if i := sort.SearchStrings(known, v); i == len(known) || known[i] != v {
+				added = append(added, v)
+			}
+		}
+	}
+	if !seen || len(added) > 0 || len(known) != len(current) {
+		k.values[key] = current
+		k.dirty = true
+	}
+	return added
+}
+
+// Save saves the known values to file if they changed
+func (k *KnownValues) Save() error {
+	if !k.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(k.values, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize known values: %w", err)
+	}
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(k.filePath(), data); err != nil {
+		return fmt.Errorf("failed to save known values: %w", err)
+	}
+	k.dirty = false
+	return nil
+}
+
+// filePath returns the known values file path
+func (k *KnownValues) filePath() string {
+	return filepath.Join(k.dir, knownValuesFileName)
+}
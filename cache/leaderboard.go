@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/timefmt"
 )
 
 // LeaderboardCache handles leaderboard caching
@@ -31,6 +33,9 @@ type CacheKey struct {
 	CategoryID   string
 	CategoryName string
 	Variables    map[string]string // Subcategory variables
+	Timing       string            // Timing method, empty for the game's primary timing
+	Top          int               // Number of places fetched, 0 for the API client default
+	PerCountry   int               // Derived board of each country's best runs, 0 for the full board
 }
 
 // String returns the string representation of the cache key
@@ -57,24 +62,51 @@ func (k *CacheKey) String() string {
 		}
 	}
 
+	// Non-default fetch options produce a separate cache entry
+	if k.Timing != "" {
+		parts = append(parts, "timing="+k.Timing)
+	}
+	if k.Top > 0 {
+		parts = append(parts, fmt.Sprintf("top=%d", k.Top))
+	}
+	if k.PerCountry > 0 {
+		parts = append(parts, fmt.Sprintf("percountry=%d", k.PerCountry))
+	}
+
 	return strings.Join(parts, "_")
 }
 
 // FileName returns the cache file name
 func (k *CacheKey) FileName() string {
-	return k.String() + ".csv"
+	return k.SafeName() + ".csv"
+}
+
+// SafeName returns the cache key as a name usable for files and directories on every platform
+func (k *CacheKey) SafeName() string {
+	return safeFileName(k.String())
 }
 
 // CachedLeaderboard represents cached leaderboard data
 type CachedLeaderboard struct {
-	Key      CacheKey
-	CachedAt time.Time
-	Game     models.Game
-	Category models.Category
-	Runs     []models.RunEntry
-	Players  map[string]models.PlayerData
+	Key       CacheKey
+	CachedAt  time.Time
+	Game      models.Game
+	Category  models.Category
+	Runs      []models.RunEntry
+	Players   map[string]models.PlayerData
+	Platforms map[string]string // Platform ID -> name
+	Weblink   string            // Leaderboard page on speedrun.com
+	// IDs of the runs cached by an earlier format, without platform, weblink and comment
+	Incomplete []string
 }
 
+// leaderboardFormat is the version of the cache files written by Save
+// Version 2 added the platform, weblink and comment columns (and the #PLATFORM and #WEBLINK rows)
+const leaderboardFormat = 2
+
+// leaderboardColumns is the number of columns of a version 2 row
+const leaderboardColumns = 12
+
 // GetFileName returns the cache file path
 func (c *LeaderboardCache) GetFileName(key *CacheKey) string {
 	return filepath.Join(c.dir, key.FileName())
@@ -89,17 +121,12 @@ func (c *LeaderboardCache) Save(data *CachedLeaderboard) error {
 
 	path := c.GetFileName(&data.Key)
 
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create cache file: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	// Build the file in memory, then replace the cache file atomically
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
 
 	// Write metadata header
-	writer.Write([]string{"#META", "VERSION", "1"})
+	writer.Write([]string{"#META", "VERSION", fmt.Sprintf("%d", leaderboardFormat)})
 	writer.Write([]string{"#GAME", data.Key.GameID, data.Key.GameName})
 	writer.Write([]string{"#CATEGORY", data.Key.CategoryID, data.Key.CategoryName})
 	writer.Write([]string{"#CACHED_AT", data.CachedAt.Format(time.RFC3339)})
@@ -108,11 +135,23 @@ func (c *LeaderboardCache) Save(data *CachedLeaderboard) error {
 	for key, value := range data.Key.Variables {
 		writer.Write([]string{"#VARIABLE", key, value})
 	}
+	if data.Key.Timing != "" {
+		writer.Write([]string{"#TIMING", data.Key.Timing})
+	}
+	if data.Key.Top > 0 {
+		writer.Write([]string{"#TOP", fmt.Sprintf("%d", data.Key.Top)})
+	}
+	for id, name := range data.Platforms {
+		writer.Write([]string{"#PLATFORM", id, name})
+	}
+	if data.Weblink != "" {
+		writer.Write([]string{"#WEBLINK", data.Weblink})
+	}
 
 	// Write header
 	writer.Write([]string{
 		"rank", "player_id", "player_name", "country_code", "time_seconds",
-		"date", "submit_url", "run_id", "video_links",
+		"date", "submit_url", "run_id", "video_links", "platform", "weblink", "comment",
 	})
 
 	// Write each record
@@ -152,16 +191,27 @@ func (c *LeaderboardCache) Save(data *CachedLeaderboard) error {
 				playerID,
 				playerName,
 				countryCode, // Country code (ISO Alpha-2)
-				fmt.Sprintf("%.2f", run.Run.Times.PrimaryT), // Seconds
+				timefmt.FormatCSV(run.Run.Times.PrimaryT), // Seconds
 				run.Run.Date,
 				run.Run.SubmitURL,
 				run.Run.ID,
 				strings.Join(videoLinks, "|"),
+				run.Run.System.Platform,
+				run.Run.Weblink,
+				run.Run.Comment,
 			})
 			break // Only write first player (multiplayer games may need special handling)
 		}
 	}
 
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to save cache file: %w", err)
+	}
+
 	return nil
 }
 
@@ -183,11 +233,12 @@ func (c *LeaderboardCache) Load(key *CacheKey) (*CachedLeaderboard, error) {
 	reader.FieldsPerRecord = -1
 
 	result := &CachedLeaderboard{
-		Key:     *key,
-		Players: make(map[string]models.PlayerData),
-		Game:    models.Game{ID: key.GameID, Names: models.GameNames{International: key.GameName}},
-		Category: models.Category{ID: key.CategoryID, Name: key.CategoryName},
-		Runs:    make([]models.RunEntry, 0),
+		Key:       *key,
+		Players:   make(map[string]models.PlayerData),
+		Platforms: make(map[string]string),
+		Game:      models.Game{ID: key.GameID, Names: models.GameNames{International: key.GameName}},
+		Category:  models.Category{ID: key.CategoryID, Name: key.CategoryName},
+		Runs:      make([]models.RunEntry, 0),
 	}
 
 	// Read and parse
@@ -231,6 +282,16 @@ func (c *LeaderboardCache) Load(key *CacheKey) (*CachedLeaderboard, error) {
 				if len(record) > 2 {
 					result.Key.Variables[record[1]] = record[2]
 				}
+			case "#TIMING":
+				result.Key.Timing = record[1]
+			case "#TOP":
+				fmt.Sscanf(record[1], "%d", &result.Key.Top)
+			case "#PLATFORM":
+				if len(record) > 2 {
+					result.Platforms[record[1]] = record[2]
+				}
+			case "#WEBLINK":
+				result.Weblink = record[1]
 			}
 			continue
 		}
@@ -251,26 +312,7 @@ func (c *LeaderboardCache) Load(key *CacheKey) (*CachedLeaderboard, error) {
 			countryCode := record[3] // country_code is at index 3
 
 			// Convert seconds to ISO 8601 format
-			totalSeconds := int(primaryT)
-			hours := totalSeconds / 3600
-			minutes := (totalSeconds % 3600) / 60
-			seconds := totalSeconds % 60
-			millis := int((primaryT - float64(totalSeconds)) * 100)
-
-			var primary string
-			if hours > 0 {
-				if millis > 0 {
-					primary = fmt.Sprintf("PT%dH%dM%d.%dS", hours, minutes, seconds, millis)
-				} else {
-					primary = fmt.Sprintf("PT%dH%dM%dS", hours, minutes, seconds)
-				}
-			} else {
-				if millis > 0 {
-					primary = fmt.Sprintf("PT%dM%d.%dS", minutes, seconds, millis)
-				} else {
-					primary = fmt.Sprintf("PT%dM%dS", minutes, seconds)
-				}
-			}
+			primary := timefmt.FormatISO(primaryT)
 
 			// Parse video links
 			var videoLinks []models.VideoLink
@@ -287,27 +329,21 @@ func (c *LeaderboardCache) Load(key *CacheKey) (*CachedLeaderboard, error) {
 				players = []models.Player{
 					{Rel: "user", ID: record[1]},
 				}
-				// Store country code in Players map
+				// Store the name at cache time and the country code in Players map
+				pd := result.Players[record[1]]
+				if pd.Names.International == "" {
+					pd.Names.International = record[2]
+				}
 				if countryCode != "" {
-					if pd, ok := result.Players[record[1]]; ok {
-						// Player already exists, update location
-						if pd.Location == nil {
-							pd.Location = &models.Location{}
-						}
-						if pd.Location.Country == nil {
-							pd.Location.Country = &models.Country{}
-						}
-						pd.Location.Country.Code = countryCode
-						result.Players[record[1]] = pd
-					} else {
-						// Create new player entry with country code
-						result.Players[record[1]] = models.PlayerData{
-							Location: &models.Location{
-								Country: &models.Country{Code: countryCode},
-							},
-						}
+					if pd.Location == nil {
+						pd.Location = &models.Location{}
 					}
+					if pd.Location.Country == nil {
+						pd.Location.Country = &models.Country{}
+					}
+					pd.Location.Country.Code = countryCode
 				}
+				result.Players[record[1]] = pd
 			} else {
 				players = []models.Player{
 					{Rel: "guest", Name: record[2]},
@@ -328,6 +364,19 @@ func (c *LeaderboardCache) Load(key *CacheKey) (*CachedLeaderboard, error) {
 				},
 			}
 
+			if len(record) > 9 {
+				run.Run.System.Platform = record[9] // platform is at index 9, missing in older caches
+			}
+			if len(record) > 10 {
+				run.Run.Weblink = record[10] // weblink is at index 10, missing in older caches
+			}
+			if len(record) > 11 {
+				run.Run.Comment = record[11] // comment is at index 11, missing in older caches
+			}
+			if len(record) < leaderboardColumns {
+				result.Incomplete = append(result.Incomplete, run.Run.ID)
+			}
+
 			// Add video links if any
 			if len(videoLinks) > 0 {
 				run.Run.Videos = &models.RunVideos{
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/soar/sr_exhibit/clock"
+)
+
+const (
+	// GameTTL is how long game lookups are cached
+	GameTTL = 7 * 24 * time.Hour
+	// CategoryTTL is how long category lists are cached
+	CategoryTTL = 24 * time.Hour
+	// VariableTTL is how long game variables (subcategories) are cached
+	VariableTTL = 24 * time.Hour
+	// metadataFileName is the metadata cache file name
+	metadataFileName = "metadata.json"
+)
+
+// MetadataCacheItem represents a cached API response
+type MetadataCacheItem struct {
+	Data     json.RawMessage `json:"data"`
+	CachedAt time.Time       `json:"cached_at"`
+}
+
+// MetadataCache caches game, category and variable metadata, which rarely changes
+// Entries are keyed by lookup, e.g. "game:sms" or "categories:<game ID>"
+type MetadataCache struct {
+	mu      sync.RWMutex
+	dir     string
+	entries map[string]*MetadataCacheItem
+	dirty   bool        // Marks if there are unsaved changes
+	clock   clock.Clock // Time source of the TTL checks, see SetClock
+}
+
+// NewMetadataCache creates a metadata cache, loading the existing cache file if any
+// A missing or unreadable file starts an empty cache
+func NewMetadataCache(dir string) *MetadataCache {
+	if dir == "" {
+		dir = DefaultCacheDir
+	}
+	c := &MetadataCache{
+		dir:     dir,
+		entries: make(map[string]*MetadataCacheItem),
+		clock:   clock.System,
+	}
+
+	data, err := os.ReadFile(c.filePath())
+	if err != nil {
+		return c
+	}
+	var fileCache struct {
+		Entries map[string]*MetadataCacheItem `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &fileCache); err == nil && fileCache.Entries != nil {
+		c.entries = fileCache.Entries
+	}
+	return c
+}
+
+// SetClock replaces the time source of the cache, e.g. with a clock.Fake in tests
+func (c *MetadataCache) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+// Get decodes a cached entry into v
+// Returns false if the entry doesn't exist, is older than ttl or can't be decoded
+func (c *MetadataCache) Get(key string, ttl time.Duration, v any) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, exists := c.entries[key]
+	if !exists || c.clock.Now().Sub(item.CachedAt) > ttl {
+		return false
+	}
+	return json.Unmarshal(item.Data, v) == nil
+}
+
+// Set caches v under key
+func (c *MetadataCache) Set(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s: %w", key, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &MetadataCacheItem{
+		Data:     data,
+		CachedAt: c.clock.Now(),
+	}
+	c.dirty = true
+	return nil
+}
+
+// Save saves the cache to file if it changed
+func (c *MetadataCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	fileCache := struct {
+		Entries map[string]*MetadataCacheItem `json:"entries"`
+	}{
+		Entries: c.entries,
+	}
+	data, err := json.MarshalIndent(fileCache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize metadata cache: %w", err)
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(c.filePath(), data); err != nil {
+		return fmt.Errorf("failed to save metadata cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// Clear removes all entries and the cache file, so every lookup is fetched again
+func (c *MetadataCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*MetadataCacheItem)
+	c.dirty = false
+	if err := os.Remove(c.filePath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove metadata cache: %w", err)
+	}
+	return nil
+}
+
+// filePath returns the cache file path
+func (c *MetadataCache) filePath() string {
+	return filepath.Join(c.dir, metadataFileName)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// pagesFileName is the file of the pages generated by earlier batch runs
+const pagesFileName = "pages.json"
+
+// PageRecord describes a leaderboard page generated by a batch run
+type PageRecord struct {
+	GameID       string            `json:"game_id"`
+	GameName     string            `json:"game_name"`
+	CategoryID   string            `json:"category_id"`
+	CategoryName string            `json:"category_name"`
+	Subcategory  string            `json:"subcategory,omitempty"` // Subcategory labels
+	Variables    map[string]string `json:"variables,omitempty"`   // Variable ID -> value ID
+	Retired      bool              `json:"retired,omitempty"`     // Replaced by a retirement page
+}
+
+// PageIndex remembers the leaderboard pages generated by batch runs, keyed by output path,
+// so pages of categories removed upstream can be retired instead of going silently stale
+type PageIndex struct {
+	dir   string
+	pages map[string]PageRecord
+	dirty bool
+}
+
+// NewPageIndex loads the page index, a missing or unreadable file starts empty
+func NewPageIndex(dir string) *PageIndex {
+	if dir == "" {
+		dir = DefaultCacheDir
+	}
+	p := &PageIndex{dir: dir, pages: make(map[string]PageRecord)}
+	data, err := os.ReadFile(p.filePath())
+	if err != nil {
+		return p
+	}
+	if err := json.Unmarshal(data, &p.pages); err != nil || p.pages == nil {
+		p.pages = make(map[string]PageRecord)
+	}
+	return p
+}
+
+// Set records the page generated at output
+func (p *PageIndex) Set(output string, record PageRecord) {
+	p.pages[output] = record
+	p.dirty = true
+}
+
+// Outputs returns the output paths of the recorded pages, sorted
+func (p *PageIndex) Outputs() []string {
+	outputs := make([]string, 0, len(p.pages))
+	for output := range p.pages {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// Get returns the record of the page at output
+func (p *PageIndex) Get(output string) (PageRecord, bool) {
+	record, ok := p.pages[output]
+	return record, ok
+}
+
+// Save saves the page index to file if it changed
+func (p *PageIndex) Save() error {
+	if !p.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.pages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize page index: %w", err)
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(p.filePath(), data); err != nil {
+		return fmt.Errorf("failed to save page index: %w", err)
+	}
+	p.dirty = false
+	return nil
+}
+
+// filePath returns the page index file path
+func (p *PageIndex) filePath() string {
+	return filepath.Join(p.dir, pagesFileName)
+}
@@ -0,0 +1,392 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+const (
+	// snapshotDirName is the snapshot subdirectory inside the cache directory
+	snapshotDirName = "snapshots"
+	// snapshotTimeFormat is the timestamp format used in snapshot file names
+	snapshotTimeFormat = "20060102T150405Z"
+)
+
+// SnapshotEntry represents a single leaderboard row captured in a snapshot
+type SnapshotEntry struct {
+	RunID      string  `json:"run_id"`
+	PlayerKey  string  `json:"player_key"` // See models.RunData.PlayerKey
+	PlayerName string  `json:"player_name"`
+	Place      int     `json:"place"`
+	PrimaryT   float64 `json:"primary_t"`
+	Date       string  `json:"date,omitempty"`
+}
+
+// Snapshot represents the state of a leaderboard at one generation
+type Snapshot struct {
+	Key     string          `json:"key"`
+	Title   string          `json:"title,omitempty"` // "<Game> - <Category> (<Subcategories>, <Timing>)", see SnapshotTitle
+	TakenAt time.Time       `json:"taken_at"`
+	Score   bool            `json:"score,omitempty"` // Values are scores, higher is better
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// SnapshotTitle names the board of a snapshot, telling apart boards of one category
+// subcategory: selected subcategory labels, e.g. "PC, 100%"; empty if none
+// e.g. "Super Mario Sunshine - Any% (GCN, ingame)"
+func SnapshotTitle(key *CacheKey, subcategory string) string {
+	title := key.GameName + " - " + key.CategoryName
+	var details []string
+	if subcategory != "" {
+		details = append(details, subcategory)
+	}
+	if key.Timing != "" {
+		details = append(details, key.Timing)
+	}
+	if len(details) > 0 {
+		title += " (" + strings.Join(details, ", ") + ")"
+	}
+	return title
+}
+
+// NewSnapshot builds a snapshot from leaderboard runs
+// subcategory: selected subcategory labels shown in the title, empty if none
+func NewSnapshot(key *CacheKey, subcategory string, runs []models.RunEntry, players map[string]models.PlayerData, takenAt time.Time) *Snapshot {
+	snap := &Snapshot{
+		Key:     key.String(),
+		Title:   SnapshotTitle(key, subcategory),
+		TakenAt: takenAt.UTC(),
+		Entries: make([]SnapshotEntry, 0, len(runs)),
+	}
+
+	for _, run := range runs {
+		var names []string
+		for _, p := range run.Run.Players {
+			if p.Rel == "user" {
+				if pd, ok := players[p.ID]; ok && pd.Names.International != "" {
+					names = append(names, pd.Names.International)
+				} else {
+					names = append(names, p.ID)
+				}
+			} else {
+				names = append(names, p.Name)
+			}
+		}
+
+		snap.Entries = append(snap.Entries, SnapshotEntry{
+			RunID:      run.Run.ID,
+			PlayerKey:  run.Run.PlayerKey(),
+			PlayerName: strings.Join(names, ", "),
+			Place:      run.Place,
+			PrimaryT:   run.Run.Times.PrimaryT,
+			Date:       run.Run.Date,
+		})
+	}
+
+	return snap
+}
+
+// SameStandings reports whether two snapshots contain the same runs at the same places
+func (s *Snapshot) SameStandings(other *Snapshot) bool {
+	if other == nil || len(s.Entries) != len(other.Entries) {
+		return false
+	}
+	for i := range s.Entries {
+		if s.Entries[i].RunID != other.Entries[i].RunID || s.Entries[i].Place != other.Entries[i].Place {
+			return false
+		}
+	}
+	return true
+}
+
+// SnapshotStore handles the snapshot archive, one directory per leaderboard
+type SnapshotStore struct {
+	dir string
+}
+
+// NewSnapshotStore creates a snapshot store inside the given cache directory
+func NewSnapshotStore(cacheDir string) *SnapshotStore {
+	if cacheDir == "" {
+		cacheDir = DefaultCacheDir
+	}
+	return &SnapshotStore{dir: filepath.Join(cacheDir, snapshotDirName)}
+}
+
+// keyDir returns the directory holding snapshots of one leaderboard
+func (s *SnapshotStore) keyDir(key *CacheKey) string {
+	return filepath.Join(s.dir, key.SafeName())
+}
+
+// Save writes a snapshot to the archive
+func (s *SnapshotStore) Save(key *CacheKey, snap *Snapshot) error {
+	dir := s.keyDir(key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize snapshot: %w", err)
+	}
+
+	path := filepath.Join(dir, snap.TakenAt.UTC().Format(snapshotTimeFormat)+".json")
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to save snapshot file: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the snapshot file paths of a leaderboard, oldest first
+func (s *SnapshotStore) List(key *CacheKey) ([]string, error) {
+	return s.listBoard(key.SafeName())
+}
+
+// listBoard returns the snapshot file paths of a board directory, oldest first
+func (s *SnapshotStore) listBoard(board string) ([]string, error) {
+	dir := filepath.Join(s.dir, board)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// Timestamped names sort chronologically
+	sort.Strings(files)
+	return files, nil
+}
+
+// Boards returns the names of all boards that have snapshots (see CacheKey.SafeName)
+func (s *SnapshotStore) Boards() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	var boards []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			boards = append(boards, entry.Name())
+		}
+	}
+	sort.Strings(boards)
+	return boards, nil
+}
+
+// History loads all snapshots of a board, oldest first
+func (s *SnapshotStore) History(board string) ([]*Snapshot, error) {
+	files, err := s.listBoard(board)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*Snapshot, 0, len(files))
+	for _, file := range files {
+		snap, err := loadSnapshot(file)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, snap)
+	}
+	return history, nil
+}
+
+// Latest returns the most recent snapshot of a leaderboard, or nil if none exists
+func (s *SnapshotStore) Latest(key *CacheKey) (*Snapshot, error) {
+	files, err := s.List(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return loadSnapshot(files[len(files)-1])
+}
+
+// Recent returns the last n snapshots of a leaderboard, oldest first
+func (s *SnapshotStore) Recent(key *CacheKey, n int) ([]*Snapshot, error) {
+	files, err := s.List(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > n {
+		files = files[len(files)-n:]
+	}
+
+	recent := make([]*Snapshot, 0, len(files))
+	for _, file := range files {
+		snap, err := loadSnapshot(file)
+		if err != nil {
+			return nil, err
+		}
+		recent = append(recent, snap)
+	}
+	return recent, nil
+}
+
+// Since returns the snapshots of a leaderboard taken at or after t, oldest first, preceded by the last one
+// taken before t as their baseline
+func (s *SnapshotStore) Since(key *CacheKey, t time.Time) ([]*Snapshot, error) {
+	files, err := s.List(key)
+	if err != nil {
+		return nil, err
+	}
+	first := len(files)
+	for i, file := range files {
+		taken, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err == nil && !taken.Before(t) {
+			first = i
+			break
+		}
+	}
+	if first > 0 {
+		first--
+	}
+
+	since := make([]*Snapshot, 0, len(files)-first)
+	for _, file := range files[first:] {
+		snap, err := loadSnapshot(file)
+		if err != nil {
+			return nil, err
+		}
+		since = append(since, snap)
+	}
+	return since, nil
+}
+
+// Placement is a player's standing on one snapshot
+type Placement struct {
+	TakenAt time.Time
+	Place   int     // 0 if the player was not on the board
+	Time    float64 // Primary time in seconds, 0 if the player was not on the board
+}
+
+// Placements returns the time series of every player of the snapshots, keyed by player key
+// Each series has one placement per snapshot, in the order of the snapshots
+func Placements(snaps []*Snapshot) map[string][]Placement {
+	series := make(map[string][]Placement)
+	for i, snap := range snaps {
+		for _, e := range snap.Entries {
+			points, ok := series[e.PlayerKey]
+			if !ok {
+				points = make([]Placement, len(snaps))
+				for j := range points {
+					points[j].TakenAt = snaps[j].TakenAt
+				}
+				series[e.PlayerKey] = points
+			}
+			// A player with several runs keeps the best place
+			if points[i].Place == 0 {
+				points[i].Place, points[i].Time = e.Place, e.PrimaryT
+			}
+		}
+	}
+	return series
+}
+
+// Retention selects the snapshots of each board kept by Prune
+// The latest snapshot of a board is always kept, it is the baseline of the next comparison
+type Retention struct {
+	KeepLast   int // Most recent snapshots kept
+	KeepWeekly int // Weeks, counted back from now, whose last snapshot is kept, e.g. 52 for a year
+}
+
+// Enabled reports whether the policy prunes anything, a zero Retention keeps every snapshot
+func (r Retention) Enabled() bool {
+	return r.KeepLast > 0 || r.KeepWeekly > 0
+}
+
+// keep reports which snapshots the policy keeps, given their times oldest first
+func (r Retention) keep(times []time.Time, now time.Time) []bool {
+	kept := make([]bool, len(times))
+	for i := len(times) - 1; i >= 0 && i >= len(times)-max(r.KeepLast, 1); i-- {
+		kept[i] = true
+	}
+
+	weeks := make(map[string]bool)
+	since := now.AddDate(0, 0, -7*r.KeepWeekly)
+	for i := len(times) - 1; i >= 0 && r.KeepWeekly > 0; i-- {
+		if times[i].Before(since) {
+			break
+		}
+		year, week := times[i].UTC().ISOWeek()
+		if key := fmt.Sprintf("%d-%d", year, week); !weeks[key] {
+			weeks[key] = true
+			kept[i] = true
+		}
+	}
+	return kept
+}
+
+// Prune deletes the snapshots of every board the retention policy doesn't keep
+// Returns the number of deleted snapshots
+func (s *SnapshotStore) Prune(r Retention, now time.Time) (int, error) {
+	if !r.Enabled() {
+		return 0, nil
+	}
+	boards, err := s.Boards()
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, board := range boards {
+		files, err := s.listBoard(board)
+		if err != nil {
+			return deleted, err
+		}
+		var paths []string
+		var times []time.Time
+		for _, file := range files {
+			// Files not named by the snapshot time are not ours, leave them alone
+			t, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(filepath.Base(file), ".json"))
+			if err != nil {
+				continue
+			}
+			paths = append(paths, file)
+			times = append(times, t)
+		}
+		for i, keep := range r.keep(times, now) {
+			if keep {
+				continue
+			}
+			if err := os.Remove(paths[i]); err != nil {
+				return deleted, fmt.Errorf("failed to delete snapshot: %w", err)
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// loadSnapshot reads a snapshot file
+func loadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot file %s: %w", path, err)
+	}
+	return &snap, nil
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionKeep(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) // Friday
+	day := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+	// Oldest first: two a year and a half ago, two in an earlier week, three this week
+	times := []time.Time{day(560), day(558), day(13), day(12), day(3), day(2), day(0)}
+
+	tests := []struct {
+		name string
+		r    Retention
+		want []bool
+	}{
+		{"last", Retention{KeepLast: 2}, []bool{false, false, false, false, false, true, true}},
+		{"latest always kept", Retention{KeepWeekly: 0, KeepLast: 0}, []bool{false, false, false, false, false, false, true}},
+		{"weekly", Retention{KeepWeekly: 52}, []bool{false, false, false, true, false, false, true}},
+		{"weekly and last", Retention{KeepLast: 3, KeepWeekly: 52}, []bool{false, false, false, true, true, true, true}},
+		{"weekly beyond the history", Retention{KeepWeekly: 104}, []bool{false, true, false, true, false, false, true}},
+	}
+	for _, tt := range tests {
+		got := tt.r.keep(times, now)
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: keep = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
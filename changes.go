@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// computeMovements compares current runs against the previous snapshot
+// Returns rank movement keyed by run ID, or nil if there is no previous snapshot
+func computeMovements(prev *cache.Snapshot, runs []models.RunEntry) map[string]generator.RankMovement {
+	if prev == nil {
+		return nil
+	}
+
+	// Previous place of each player (or player group for multiplayer runs)
+	prevPlaces := make(map[string]int, len(prev.Entries))
+	for _, e := range prev.Entries {
+		if _, seen := prevPlaces[e.PlayerKey]; !seen {
+			prevPlaces[e.PlayerKey] = e.Place
+		}
+	}
+
+	movements := make(map[string]generator.RankMovement, len(runs))
+	for _, run := range runs {
+		prevPlace, found := prevPlaces[run.Run.PlayerKey()]
+		if !found {
+			movements[run.Run.ID] = generator.RankMovement{New: true}
+			continue
+		}
+		movements[run.Run.ID] = generator.RankMovement{Delta: prevPlace - run.Place}
+	}
+	return movements
+}
+
+// computeTrajectories returns the standings of each run's player on the snapshots, oldest first
+// Returns nil if there are fewer than two snapshots, a single point shows no trajectory
+func computeTrajectories(history []*cache.Snapshot, runs []models.RunEntry) map[string]generator.Trajectory {
+	if len(history) < 2 {
+		return nil
+	}
+
+	series := cache.Placements(history)
+	trajectories := make(map[string]generator.Trajectory, len(runs))
+	for _, run := range runs {
+		placements := series[run.Run.PlayerKey()]
+		points := make(generator.Trajectory, len(placements))
+		for i, p := range placements {
+			points[i] = generator.TrajectoryPoint{TakenAt: p.TakenAt, Place: p.Place, Time: p.Time}
+		}
+		trajectories[run.Run.ID] = points
+	}
+	return trajectories
+}
+
+// heatmapFade returns the configured display.heatmap, 0 if the heatmap is disabled
+func heatmapFade(config models.Config) (time.Duration, error) {
+	if config.Display.Heatmap == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(config.Display.Heatmap)
+	if err != nil {
+		return 0, fmt.Errorf("invalid display.heatmap: %w", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("display.heatmap must not be negative")
+	}
+	return d, nil
+}
+
+// computeHeat rates how much each run's player improved recently, keyed by run ID, from 0 to 1
+// The last improvement of a player on the snapshots (a lower time than on the snapshot before) counts,
+// relative to the biggest one on the board, and fades out linearly over fade since it was recorded.
+// Players without an improvement within fade, including new entries, are left out.
+func computeHeat(history []*cache.Snapshot, runs []models.RunEntry, now time.Time, fade time.Duration) map[string]float64 {
+	if len(history) < 2 || fade <= 0 {
+		return nil
+	}
+
+	type improvement struct {
+		saved float64 // Fraction of the previous time saved
+		age   time.Duration
+	}
+	series := cache.Placements(history)
+	improvements := make(map[string]improvement, len(runs))
+	maxSaved := 0.0
+	for _, run := range runs {
+		points := series[run.Run.PlayerKey()]
+		for i := len(points) - 1; i > 0; i-- {
+			age := now.Sub(points[i].TakenAt)
+			if age >= fade {
+				break
+			}
+			prev, cur := points[i-1], points[i]
+			if prev.Place == 0 || cur.Place == 0 || cur.Time >= prev.Time || prev.Time <= 0 {
+				continue
+			}
+			saved := (prev.Time - cur.Time) / prev.Time
+			improvements[run.Run.ID] = improvement{saved: saved, age: age}
+			maxSaved = math.Max(maxSaved, saved)
+			break
+		}
+	}
+	if len(improvements) == 0 {
+		return nil
+	}
+
+	// The square root keeps small improvements visible next to a big one
+	heat := make(map[string]float64, len(improvements))
+	for id, imp := range improvements {
+		fading := 1 - math.Max(imp.age.Seconds(), 0)/fade.Seconds()
+		heat[id] = fading * math.Sqrt(imp.saved/maxSaved)
+	}
+	return heat
+}
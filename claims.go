@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/soar/sr_exhibit/diff"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// claim is a new world record waiting to be cross-checked before it is announced
+type claim struct {
+	board  *boardResult
+	change diff.Change
+}
+
+// checkClaims cross-checks the new world records of the session with the records endpoint (claims.verify)
+// A record the endpoints disagree on is withheld from the notifications and alerted as claimMismatch instead;
+// a record that can't be checked is withheld too, so stale data never gets announced
+func (s *session) checkClaims(ctx context.Context, opts timefmt.Options) {
+	for _, c := range s.claims {
+		records, err := s.client.GetRecords(ctx, c.board.Category.ID, 1)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Claims: %s: %v, the new world record is not announced\n", c.change.Board, err)
+			s.notifier.withhold(c.change)
+			continue
+		}
+		if reason := claimMismatchReason(c.board, records, opts); reason != "" {
+			fmt.Fprintf(os.Stderr, "Warning: Claims: %s: %s, the new world record is not announced\n", c.change.Board, reason)
+			s.notifier.withhold(c.change)
+			s.notifier.alert(c.change, reason, c.board.Leaderboard.Players.M)
+			continue
+		}
+		fmt.Printf("Claims: %s: world record consistent with the records endpoint\n", c.change.Board)
+	}
+	s.claims = nil
+}
+
+// claimMismatchReason compares the world record of a board with the top runs of the records endpoint
+// Returns why they disagree, or "" if they agree or the records board can't tell
+// The records board ranks every subcategory together by the primary timing: its record must be at least
+// as fast as the board's, and must be the board's record if it belongs to the board
+func claimMismatchReason(board *boardResult, records []models.RunEntry, opts timefmt.Options) string {
+	runs := board.Leaderboard.Runs
+	if len(runs) == 0 {
+		return ""
+	}
+	wr := runs[0].Run
+	if len(records) == 0 {
+		return fmt.Sprintf("the leaderboard shows %s as the world record, the records endpoint has no record", claimRun(wr, board, opts))
+	}
+	for _, r := range records {
+		if r.Place == 1 && r.Run.ID == wr.ID {
+			return ""
+		}
+	}
+	if board.Key.Timing != "" {
+		return "" // Ranked by another timing than the records board
+	}
+
+	record := records[0].Run
+	recordT, wrT := timefmt.Millis(record.Times.PrimaryT), timefmt.Millis(wr.Times.PrimaryT)
+	// Merged boards span several subcategories, per-country boards leave out runs without a country
+	sameBoard := board.Sources == nil && board.Key.PerCountry == 0
+	for id, value := range board.Key.Variables {
+		sameBoard = sameBoard && record.Values[id] == value
+	}
+	if (sameBoard && recordT != wrT) || (!board.Score && wrT < recordT) {
+		return fmt.Sprintf("the leaderboard shows %s as the world record, the records endpoint shows %s",
+			claimRun(wr, board, opts), claimRun(record, board, opts))
+	}
+	return ""
+}
+
+// claimRun describes a run of a claim, e.g. "Bob's 1:01:40.500"
+func claimRun(run models.RunData, board *boardResult, opts timefmt.Options) string {
+	var names []string
+	for _, p := range run.Players {
+		if p.Rel != "user" {
+			names = append(names, p.Name)
+		} else if pd, ok := board.Leaderboard.Players.M[p.ID]; ok && pd.Names.International != "" {
+			names = append(names, pd.Names.International)
+		} else {
+			names = append(names, p.ID)
+		}
+	}
+	value := timefmt.FormatSeconds(run.Times.PrimaryT, opts)
+	if board.Score {
+		value = timefmt.FormatScore(run.Times.PrimaryT)
+	}
+	return strings.Join(names, ", ") + "'s " + value
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// claimEntry returns a run by a guest at a place, with its subcategory values
+func claimEntry(id, player string, place int, primaryT float64, values map[string]string) models.RunEntry {
+	return models.RunEntry{Place: place, Run: models.RunData{
+		ID:      id,
+		Players: []models.Player{{Rel: "guest", Name: player}},
+		Times:   models.RunTimes{PrimaryT: primaryT},
+		Values:  values,
+	}}
+}
+
+func TestClaimMismatchReason(t *testing.T) {
+	pc := map[string]string{"platform": "pc"}
+	console := map[string]string{"platform": "console"}
+	wr := claimEntry("r1", "alice", 1, 100, pc)
+	tests := []struct {
+		name     string
+		key      cache.CacheKey
+		sources  map[string]string
+		score    bool
+		runs     []models.RunEntry
+		records  []models.RunEntry
+		mismatch bool
+	}{
+		{name: "empty leaderboard", records: []models.RunEntry{wr}},
+		{name: "same board agreeing", key: cache.CacheKey{Variables: pc}, runs: []models.RunEntry{wr}, records: []models.RunEntry{wr}},
+		{
+			name:     "same board, faster record",
+			key:      cache.CacheKey{Variables: pc},
+			runs:     []models.RunEntry{wr},
+			records:  []models.RunEntry{claimEntry("r2", "bob", 1, 95, pc)},
+			mismatch: true,
+		},
+		{
+			name:     "same board, slower record",
+			key:      cache.CacheKey{Variables: pc},
+			runs:     []models.RunEntry{wr},
+			records:  []models.RunEntry{claimEntry("r2", "bob", 1, 105, pc)},
+			mismatch: true,
+		},
+		{
+			name:    "same board, tied record",
+			key:     cache.CacheKey{Variables: pc},
+			runs:    []models.RunEntry{wr},
+			records: []models.RunEntry{claimEntry("r2", "bob", 1, 100, pc), wr},
+		},
+		{
+			name:    "faster record of another subcategory",
+			key:     cache.CacheKey{Variables: pc},
+			runs:    []models.RunEntry{wr},
+			records: []models.RunEntry{claimEntry("r2", "bob", 1, 95, console)},
+		},
+		{
+			name:    "merged board, faster record",
+			key:     cache.CacheKey{Variables: map[string]string{"platform": "pc+console"}},
+			sources: map[string]string{"r1": "PC"},
+			runs:    []models.RunEntry{wr},
+			records: []models.RunEntry{claimEntry("r2", "bob", 1, 95, console)},
+		},
+		{
+			name:     "merged board, slower record",
+			key:      cache.CacheKey{Variables: map[string]string{"platform": "pc+console"}},
+			sources:  map[string]string{"r1": "PC"},
+			runs:     []models.RunEntry{wr},
+			records:  []models.RunEntry{claimEntry("r2", "bob", 1, 105, console)},
+			mismatch: true,
+		},
+		{
+			name:    "per-country board, faster record",
+			key:     cache.CacheKey{Variables: pc, PerCountry: 1},
+			runs:    []models.RunEntry{wr},
+			records: []models.RunEntry{claimEntry("r2", "bob", 1, 95, pc)},
+		},
+		{
+			name:     "score board disagreeing",
+			key:      cache.CacheKey{Variables: pc},
+			score:    true,
+			runs:     []models.RunEntry{claimEntry("r1", "alice", 1, 5000, pc)},
+			records:  []models.RunEntry{claimEntry("r2", "bob", 1, 6000, pc)},
+			mismatch: true,
+		},
+		{
+			name:    "merged score board, record of another subcategory",
+			key:     cache.CacheKey{Variables: map[string]string{"platform": "pc+console"}},
+			sources: map[string]string{"r1": "PC"},
+			score:   true,
+			runs:    []models.RunEntry{claimEntry("r1", "alice", 1, 5000, pc)},
+			records: []models.RunEntry{claimEntry("r2", "bob", 1, 4000, console)},
+		},
+		{
+			name:    "timing board",
+			key:     cache.CacheKey{Variables: pc, Timing: "realtime"},
+			runs:    []models.RunEntry{wr},
+			records: []models.RunEntry{claimEntry("r2", "bob", 1, 95, pc)},
+		},
+		{
+			name:     "empty records endpoint",
+			key:      cache.CacheKey{Variables: pc},
+			runs:     []models.RunEntry{wr},
+			mismatch: true,
+		},
+	}
+	for _, tt := range tests {
+		key := tt.key
+		board := &boardResult{
+			Key:         &key,
+			Leaderboard: &models.LeaderboardData{Runs: tt.runs},
+			Sources:     tt.sources,
+			Score:       tt.score,
+		}
+		reason := claimMismatchReason(board, tt.records, timefmt.Options{})
+		if (reason != "") != tt.mismatch {
+			t.Errorf("%s: reason = %q, want mismatch %v", tt.name, reason, tt.mismatch)
+		}
+	}
+}
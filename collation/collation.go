@@ -0,0 +1,70 @@
+// Package collation orders and matches player names by the rules of a language instead of byte order,
+// which misorders the non-ASCII names common on speedrun boards
+package collation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Collation is the name order of a language, e.g. Japanese orders kana by the gojūon
+// Case, diacritics and character width are ignored, as people search names
+type Collation struct {
+	Tag language.Tag
+}
+
+// New returns the collation of a BCP 47 language tag (e.g. "ja", "zh-Hans"), "" for the root order
+func New(lang string) (Collation, error) {
+	if lang == "" {
+		return Collation{Tag: language.Und}, nil
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return Collation{}, fmt.Errorf("invalid collation %q: %w", lang, err)
+	}
+	return Collation{Tag: tag}, nil
+}
+
+// Compare returns a comparison of names (-1, 0 or +1) in the order of the collation
+// The function is not safe for concurrent use, get one per goroutine
+func (c Collation) Compare() func(a, b string) int {
+	collator := collate.New(c.Tag, collate.Loose, collate.Numeric)
+	return collator.CompareString
+}
+
+// Fold returns the search key of a name: two names with the same key match, e.g. "Zoë", "ZOE" and "ｚｏｅ",
+// or "スズキ" and "すずき"; punctuation and spaces are dropped
+func Fold(name string) string {
+	folded, _, err := transform.String(folder(), name)
+	if err != nil {
+		folded = strings.ToLower(name)
+	}
+	var b strings.Builder
+	for _, r := range folded {
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ' // Katakana to hiragana
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// folder returns the case, width and diacritic folding of Fold; transformers are not safe for concurrent use
+// The kana voicing marks are kept, "ガ" and "カ" are different names
+// Compatibility decomposition (NFKD) folds the width, as String.normalize does in the hub search (see generator/hub.html)
+func folder() transform.Transformer {
+	diacritic := runes.Predicate(func(r rune) bool {
+		return unicode.Is(unicode.Mn, r) && r != '\u3099' && r != '\u309A'
+	})
+	return transform.Chain(norm.NFKD, runes.Remove(diacritic), cases.Fold(), norm.NFC)
+}
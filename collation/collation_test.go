@@ -0,0 +1,62 @@
+package collation
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"Soar_Qin", "soarqin"},
+		{"Zoë", "ZOE"},
+		{"ｚｏｅ", "zoe"},
+		{"スズキ", "すずき"},
+		{"ｽｽﾞｷ", "すずき"},
+		{"Straße", "STRASSE"},
+	}
+	for _, tt := range tests {
+		if Fold(tt.a) != Fold(tt.b) {
+			t.Errorf("Fold(%q) = %q, Fold(%q) = %q, want equal", tt.a, Fold(tt.a), tt.b, Fold(tt.b))
+		}
+	}
+	if Fold("ガ") == Fold("カ") {
+		t.Error("Fold drops the kana voicing mark")
+	}
+}
+
+func TestFoldKeys(t *testing.T) {
+	// The hub search folds what is typed in the browser to these keys too (fold in generator/hub.html)
+	tests := []struct{ name, want string }{
+		{"Straße", "strasse"},
+		{"STRASSE", "strasse"},
+		{"ΟΔΟΣ", "οδοσ"},
+		{"ǅemal", "dzemal"},
+		{"ｽｽﾞｷ", "すずき"},
+		{"Player①", "player1"},
+	}
+	for _, tt := range tests {
+		if got := Fold(tt.name); got != tt.want {
+			t.Errorf("Fold(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	c, err := New("ja")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare := c.Compare()
+	names := []string{"zed", "Émile", "すずき", "player10", "Adam", "カトウ", "player9", "ábel", "あおき"}
+	sort.SliceStable(names, func(i, j int) bool { return compare(names[i], names[j]) < 0 })
+	want := []string{"ábel", "Adam", "Émile", "player9", "player10", "zed", "あおき", "カトウ", "すずき"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("order = %v, want %v", names, want)
+		}
+	}
+
+	if _, err := New("not a tag"); err == nil {
+		t.Error("New accepted an invalid tag")
+	}
+}
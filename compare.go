@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// parseCompareValues parses the --compare flag, e.g. "PC,Console"
+func parseCompareValues(value string) ([2]string, error) {
+	var values [2]string
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return values, fmt.Errorf("--compare expects two subcategory values separated by a comma, e.g. \"PC,Console\"")
+	}
+	for i, part := range parts {
+		values[i] = strings.TrimSpace(part)
+		if values[i] == "" {
+			return values, fmt.Errorf("--compare: empty subcategory value")
+		}
+	}
+	return values, nil
+}
+
+// runCompare generates a page comparing the same players across two subcategory values of a category
+// outputPath: page path, default "compare-<a>-vs-<b>.html" next to the configured output
+func runCompare(ctx context.Context, config models.Config, timeout time.Duration, clk clock.Clock, values [2]string, outputPath, templatePath string, cacheDir string, lbCache *cache.LeaderboardCache, snapshots *cache.SnapshotStore, useCache, refreshCache bool) error {
+	s := newSession(config, timeout, clk, cacheDir, lbCache, snapshots, useCache, refreshCache)
+	defer s.recordUsage(ctx, "compare")
+	if config.Category == "" {
+		return withExitCode(exitConfig, fmt.Errorf("compare mode requires a category"))
+	}
+
+	var boards [2]*boardResult
+	for i, value := range values {
+		fmt.Printf("\n[%d/2] %s - %s (%s)\n", i+1, config.Game, config.Category, value)
+		board, err := s.fetchBoard(ctx, boardSpec{
+			Game:        config.Game,
+			Category:    config.Category,
+			Subcategory: value,
+			Scoring:     config.Scoring,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", value, err)
+		}
+		boards[i] = board
+	}
+
+	// Places are renumbered before the boards are joined, rows point to their runs
+	ranking, _ := generator.NewRanking(config.Display) // Validated at startup
+	for _, board := range boards {
+		board.Leaderboard.Runs = ranking.Apply(board.Leaderboard.Runs, board.Leaderboard.Players.M)
+	}
+	data := buildCompare(boards[0], boards[1])
+	data.HideRanks = ranking.Hide
+	data.Score = boards[0].Score
+	data.LabelA, data.LabelB = values[0], values[1]
+	if boards[0].Subcategory != "" {
+		data.LabelA = boards[0].Subcategory
+	}
+	if boards[1].Subcategory != "" {
+		data.LabelB = boards[1].Subcategory
+	}
+
+	fmt.Println("\nGenerating compare page...")
+	gen, err := newGenerator(config, templatePath, s.storage)
+	if err != nil {
+		return err
+	}
+	if config.Compare.Template != "" {
+		if err := gen.SetCompareTemplate(config.Compare.Template); err != nil {
+			return withExitCode(exitGeneration, err)
+		}
+	}
+
+	if outputPath == "" {
+		// Never overwrite the leaderboard page itself
+		dir := config.Output
+		if dir == "" {
+			dir = "./output"
+		} else if filepath.Ext(dir) == ".html" {
+			dir = filepath.Dir(dir)
+		}
+		outputPath = filepath.Join(dir, "compare-"+slugify(data.LabelA+"-vs-"+data.LabelB)+".html")
+	}
+	if err := gen.GenerateCompare(outputPath, data); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to generate compare page: %w", err))
+	}
+	s.pages++
+	fmt.Printf("✓ Compare page generated: %s\n", outputPath)
+	return s.staleErr()
+}
+
+// buildCompare joins the runs of two boards by player
+// Players on both boards come first, ordered by their time on the first board, then players on one board only
+func buildCompare(a, b *boardResult) *generator.CompareData {
+	data := &generator.CompareData{
+		Game:     *a.Game,
+		Category: *a.Category,
+		Players:  make(map[string]models.PlayerData),
+	}
+	for id, pd := range a.Leaderboard.Players.M {
+		data.Players[id] = pd
+	}
+	for id, pd := range b.Leaderboard.Players.M {
+		data.Players[id] = pd
+	}
+
+	rows := make(map[string]*generator.CompareRow)
+	var order []string
+	addRuns := func(runs []models.RunEntry, first bool) {
+		for i := range runs {
+			run := &runs[i]
+			key := run.Run.PlayerKey()
+			row, ok := rows[key]
+			if !ok {
+				row = &generator.CompareRow{Players: run.Run.Players}
+				rows[key] = row
+				order = append(order, key)
+			}
+			// Keep each player's best run of a board
+			if first && row.RunA == nil {
+				row.RunA = run
+			} else if !first && row.RunB == nil {
+				row.RunB = run
+			}
+		}
+	}
+	addRuns(a.Leaderboard.Runs, true)
+	addRuns(b.Leaderboard.Runs, false)
+
+	var deltas []float64
+	for _, key := range order {
+		row := rows[key]
+		if row.HasBoth() {
+			row.Delta = row.RunB.Run.Times.PrimaryT - row.RunA.Run.Times.PrimaryT
+			deltas = append(deltas, row.Delta)
+			if row.Delta > 0 {
+				data.Summary.FasterA++
+			} else if row.Delta < 0 {
+				data.Summary.FasterB++
+			}
+		}
+		data.Rows = append(data.Rows, *row)
+	}
+
+	sort.SliceStable(data.Rows, func(i, j int) bool {
+		return compareRank(data.Rows[i]) < compareRank(data.Rows[j])
+	})
+
+	data.Summary.Common = len(deltas)
+	if len(deltas) > 0 {
+		var total float64
+		for _, d := range deltas {
+			total += d
+		}
+		data.Summary.MeanDelta = total / float64(len(deltas))
+
+		sort.Float64s(deltas)
+		mid := len(deltas) / 2
+		if len(deltas)%2 == 1 {
+			data.Summary.MedianDelta = deltas[mid]
+		} else {
+			data.Summary.MedianDelta = (deltas[mid-1] + deltas[mid]) / 2
+		}
+	}
+
+	return data
+}
+
+// compareRank returns the sort group of a compare row: both boards, first board only, second board only
+func compareRank(row generator.CompareRow) int {
+	switch {
+	case row.HasBoth():
+		return 0
+	case row.RunA != nil:
+		return 1
+	default:
+		return 2
+	}
+}
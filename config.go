@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/soar/sr_exhibit/models"
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey is the top-level config key listing files to include
+const includeKey = "include"
+
+// parseConfig parses a config file, resolving include directives
+// Included files are merged first, in order, then the including file on top of them:
+// mappings are merged key by key, lists (e.g. leaderboards) are concatenated and other values are replaced.
+func parseConfig(path string, data []byte) (models.Config, error) {
+	var config models.Config
+
+	root, err := loadConfigNode(path, data, nil)
+	if err != nil {
+		return config, err
+	}
+	if root == nil {
+		return config, nil
+	}
+	if err := root.Decode(&config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// loadConfigNode parses one config file and the files it includes into a merged mapping node
+// stack holds the absolute paths of the files currently being loaded, for cycle detection
+func loadConfigNode(path string, data []byte, stack []string) (*yaml.Node, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	for _, p := range stack {
+		if p == absPath {
+			return nil, fmt.Errorf("include cycle: %s", includeChain(append(stack, absPath)))
+		}
+	}
+	stack = append(stack, absPath)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", includeChain(stack), err)
+	}
+	if len(doc.Content) == 0 {
+		// Empty file
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: config must be a mapping", includeChain(stack))
+	}
+
+	includes, err := takeIncludes(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", includeChain(stack), err)
+	}
+	if len(stack) > 1 {
+		// Paths in an included file are relative to that file
+		rebasePaths(root, filepath.Dir(path), make(map[*yaml.Node]bool))
+	}
+
+	var merged *yaml.Node
+	for _, pattern := range includes {
+		files, err := resolveInclude(filepath.Dir(path), pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", includeChain(stack), err)
+		}
+		for _, file := range files {
+			included, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: failed to read include: %w", includeChain(stack), err)
+			}
+			node, err := loadConfigNode(file, included, stack)
+			if err != nil {
+				return nil, err
+			}
+			merged = mergeNodes(merged, node)
+		}
+	}
+
+	return mergeNodes(merged, root), nil
+}
+
+// Path fields of the config, relative to the working directory unless noted
+var (
+	configPathKeys      = []string{"template", "output", "assetsDir", "statsFile"}
+	defaultsPathKeys    = []string{"template", "outputDir"}
+	hubPathKeys         = []string{"template", "output"}
+	comparePathKeys     = []string{"template"}
+	leaderboardPathKeys = []string{"template", "output"}
+	exhibitionPathKeys  = []string{"outputDir"}
+	brandingPathKeys    = []string{"logo", "assetsDir"}
+)
+
+// rebasePaths rewrites the relative path fields of an included file's mapping node to be relative to baseDir
+// cache.dir is made absolute, since the main config resolves it against its own directory
+// seen guards against rewriting a node shared through anchors twice
+func rebasePaths(root *yaml.Node, baseDir string, seen map[*yaml.Node]bool) {
+	rebase := func(node *yaml.Node, keys []string) {
+		forEachValue(node, keys, seen, func(value *yaml.Node) {
+			value.Value = filepath.Join(baseDir, value.Value)
+		})
+	}
+
+	rebase(root, configPathKeys)
+	forEachValue(root, []string{"cache"}, nil, func(cacheNode *yaml.Node) {
+		forEachValue(cacheNode, []string{"dir"}, seen, func(value *yaml.Node) {
+			if abs, err := filepath.Abs(filepath.Join(baseDir, value.Value)); err == nil {
+				value.Value = abs
+			}
+		})
+	})
+	forEachValue(root, []string{"defaults"}, nil, func(node *yaml.Node) { rebase(node, defaultsPathKeys) })
+	forEachValue(root, []string{"hub"}, nil, func(node *yaml.Node) { rebase(node, hubPathKeys) })
+	forEachValue(root, []string{"compare"}, nil, func(node *yaml.Node) { rebase(node, comparePathKeys) })
+	rebaseBoards := func(list *yaml.Node) {
+		if list.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, entry := range list.Content {
+			rebase(resolveAlias(entry), leaderboardPathKeys)
+		}
+	}
+	forEachValue(root, []string{"leaderboards"}, nil, rebaseBoards)
+	forEachValue(root, []string{"exhibition"}, nil, func(node *yaml.Node) {
+		rebase(node, exhibitionPathKeys)
+		forEachValue(node, []string{"branding"}, nil, func(branding *yaml.Node) { rebase(branding, brandingPathKeys) })
+		forEachValue(node, []string{"boards"}, nil, rebaseBoards)
+	})
+}
+
+// forEachValue calls fn with the values of the given keys in a mapping node, including merged ("<<") mappings
+// With seen set, scalar values are visited once and only if they hold a relative path
+func forEachValue(node *yaml.Node, keys []string, seen map[*yaml.Node]bool, fn func(*yaml.Node)) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, resolveAlias(node.Content[i+1])
+		if key == "<<" {
+			merges := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				merges = value.Content
+			}
+			for _, merge := range merges {
+				forEachValue(merge, keys, seen, fn)
+			}
+			continue
+		}
+		for _, k := range keys {
+			if key != k {
+				continue
+			}
+			if seen != nil {
+				if value.Kind != yaml.ScalarNode || value.Value == "" || filepath.IsAbs(value.Value) || seen[value] {
+					break
+				}
+				seen[value] = true
+			}
+			fn(value)
+			break
+		}
+	}
+}
+
+// takeIncludes removes the include key from a mapping node and returns its patterns
+// The value may be a single path or a list of paths
+func takeIncludes(root *yaml.Node) ([]string, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != includeKey {
+			continue
+		}
+		value := root.Content[i+1]
+		root.Content = append(root.Content[:i:i], root.Content[i+2:]...)
+
+		var includes []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			includes = []string{value.Value}
+		case yaml.SequenceNode:
+			if err := value.Decode(&includes); err != nil {
+				return nil, fmt.Errorf("invalid include list: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("include must be a path or a list of paths")
+		}
+		return includes, nil
+	}
+	return nil, nil
+}
+
+// resolveInclude expands an include pattern relative to the including file's directory
+// Glob patterns are expanded in lexical order, a pattern matching no file is an error
+func resolveInclude(baseDir, pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty include path")
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(baseDir, pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %s: %w", pattern, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("include %s: no such file", pattern)
+	}
+	return files, nil
+}
+
+// mergeNodes merges override on top of base and returns the result
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+	base, override = resolveAlias(base), resolveAlias(override)
+
+	switch {
+	case base.Kind == yaml.MappingNode && override.Kind == yaml.MappingNode:
+		merged := &yaml.Node{Kind: yaml.MappingNode, Tag: base.Tag}
+		merged.Content = append(merged.Content, base.Content...)
+		for i := 0; i+1 < len(override.Content); i += 2 {
+			key, value := override.Content[i], override.Content[i+1]
+			found := false
+			for j := 0; j+1 < len(merged.Content); j += 2 {
+				if merged.Content[j].Value == key.Value {
+					merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+					found = true
+					break
+				}
+			}
+			if !found {
+				merged.Content = append(merged.Content, key, value)
+			}
+		}
+		return merged
+	case base.Kind == yaml.SequenceNode && override.Kind == yaml.SequenceNode:
+		merged := &yaml.Node{Kind: yaml.SequenceNode, Tag: base.Tag}
+		merged.Content = append(merged.Content, base.Content...)
+		merged.Content = append(merged.Content, override.Content...)
+		return merged
+	default:
+		return override
+	}
+}
+
+// resolveAlias returns the node an alias points to, anchors are local to their file
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// includeChain formats an include chain for error messages, e.g. "config.yaml -> games/sms.yaml"
+func includeChain(stack []string) string {
+	wd, _ := os.Getwd()
+	names := make([]string, len(stack))
+	for i, p := range stack {
+		// Show paths relative to the working directory when possible
+		if rel, err := filepath.Rel(wd, p); err == nil && !strings.HasPrefix(rel, "..") {
+			p = rel
+		}
+		names[i] = p
+	}
+	return strings.Join(names, " -> ")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/publish"
+	"github.com/soar/sr_exhibit/storage"
+)
+
+// validatePublish checks the deploy targets of the config
+func validatePublish(config models.Config) error {
+	if !config.Publish.IPFS.Enabled {
+		return nil
+	}
+	if config.Storage.Type != "" && config.Storage.Type != storage.TypeFS {
+		return fmt.Errorf("publish.ipfs needs local output files (storage.type %s)", storage.TypeFS)
+	}
+	_, err := publish.NewIPFS(config.Publish.IPFS.API)
+	return err
+}
+
+// publishOutput publishes the output directory to the configured deploy targets after a successful generation
+// batch selects the default directory: the batch output directory, or the directory of the single page
+func publishOutput(ctx context.Context, config models.Config, batch bool) error {
+	if !config.Publish.IPFS.Enabled {
+		return nil
+	}
+	dir := config.Publish.IPFS.Dir
+	if dir == "" && batch {
+		dir = batchOutputDir(config.Defaults)
+	} else if dir == "" {
+		dir = config.Output
+		if filepath.Ext(dir) != "" {
+			dir = filepath.Dir(dir)
+		}
+	}
+
+	ipfs, _ := publish.NewIPFS(config.Publish.IPFS.API) // Validated at startup
+	fmt.Printf("Publishing %s to IPFS...\n", dir)
+	cid, err := ipfs.AddDir(ctx, dir)
+	if err != nil {
+		return withExitCode(exitPublish, fmt.Errorf("failed to publish to IPFS: %w", err))
+	}
+	fmt.Printf("✓ Published to IPFS: %s\n", cid)
+	fmt.Printf("  https://ipfs.io/ipfs/%s/\n", cid)
+	return nil
+}
@@ -0,0 +1,157 @@
+// Package diff compares snapshots of a leaderboard, for the digest, notifications
+// and tools built on the library such as chat bots
+package diff
+
+import (
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// Kind identifies the type of a leaderboard change between two snapshots
+type Kind string
+
+const (
+	NewWR        Kind = "new_wr"        // A run took the #1 spot with a better value than the record, ties don't count
+	TimeSave     Kind = "time_save"     // A player improved their time, or score on score boards
+	PlacesGained Kind = "places_gained" // A player moved up
+	NewEntry     Kind = "new_entry"     // A player appeared on the board
+)
+
+// Kinds lists every change kind
+var Kinds = []Kind{NewWR, TimeSave, PlacesGained, NewEntry}
+
+// ValidKind reports whether k is a known change kind
+func ValidKind(k Kind) bool {
+	for _, kind := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Change represents one change of a player between two snapshots
+type Change struct {
+	Kind       Kind
+	Board      string // Snapshot title
+	PlayerKey  string // See models.RunData.PlayerKey
+	PlayerName string
+	RunID      string
+	OldPlace   int // 0 for new entries
+	NewPlace   int
+	OldTime    float64 // 0 for new entries; the beaten record for new world records
+	NewTime    float64
+	PrevHolder string // Previous #1 player name, for new world records
+	Score      bool   // Values are scores, higher is better
+}
+
+// TimeSaved returns the improvement versus the previous value: seconds saved, or points gained on score boards
+func (c Change) TimeSaved() float64 {
+	if c.OldTime == 0 {
+		return 0
+	}
+	if c.Score {
+		return c.NewTime - c.OldTime
+	}
+	return c.OldTime - c.NewTime
+}
+
+// PlacesGained returns the number of places gained
+func (c Change) PlacesGained() int {
+	if c.OldPlace == 0 {
+		return 0
+	}
+	return c.OldPlace - c.NewPlace
+}
+
+// Compare lists the changes from an older to a newer snapshot of the same board
+// A player can appear several times with different kinds (e.g. time save and places gained).
+// Tying the record is not a new world record.
+// Returns nil if either snapshot is nil.
+func Compare(old, cur *cache.Snapshot) []Change {
+	if old == nil || cur == nil {
+		return nil
+	}
+
+	prev := make(map[string]cache.SnapshotEntry, len(old.Entries))
+	for _, e := range old.Entries {
+		if _, seen := prev[e.PlayerKey]; !seen {
+			prev[e.PlayerKey] = e
+		}
+	}
+
+	var prevWR *cache.SnapshotEntry
+	wasFirst := make(map[string]bool) // Run IDs at #1, several on a tie
+	for i := range old.Entries {
+		if old.Entries[i].Place == 1 {
+			if prevWR == nil {
+				prevWR = &old.Entries[i]
+			}
+			wasFirst[old.Entries[i].RunID] = true
+		}
+	}
+
+	var changes []Change
+	seen := make(map[string]bool, len(cur.Entries))
+	for _, e := range cur.Entries {
+		if seen[e.PlayerKey] {
+			continue
+		}
+		seen[e.PlayerKey] = true
+
+		base := Change{
+			Board:      cur.Title,
+			PlayerKey:  e.PlayerKey,
+			PlayerName: e.PlayerName,
+			RunID:      e.RunID,
+			NewPlace:   e.Place,
+			NewTime:    e.PrimaryT,
+			Score:      cur.Score,
+		}
+
+		p, found := prev[e.PlayerKey]
+		if found {
+			base.OldPlace = p.Place
+			base.OldTime = p.PrimaryT
+		}
+
+		if e.Place == 1 && !wasFirst[e.RunID] && (prevWR == nil || Better(e.PrimaryT, prevWR.PrimaryT, cur.Score)) {
+			c := base
+			c.Kind = NewWR
+			if prevWR != nil {
+				// Compare against the record that was beaten
+				c.PrevHolder = prevWR.PlayerName
+				c.OldTime = prevWR.PrimaryT
+			}
+			changes = append(changes, c)
+		}
+
+		if !found {
+			c := base
+			c.Kind = NewEntry
+			changes = append(changes, c)
+			continue
+		}
+		if p.RunID != e.RunID && Better(e.PrimaryT, p.PrimaryT, cur.Score) {
+			c := base
+			c.Kind = TimeSave
+			changes = append(changes, c)
+		}
+		if e.Place < p.Place {
+			c := base
+			c.Kind = PlacesGained
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Better reports whether value a ranks ahead of b: a lower time, or a higher score on score boards
+// Values are compared in whole milliseconds (see timefmt.Millis), so float artifacts never break a tie
+func Better(a, b float64, score bool) bool {
+	ma, mb := timefmt.Millis(a), timefmt.Millis(b)
+	if score {
+		return ma > mb
+	}
+	return ma < mb
+}
@@ -0,0 +1,166 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/soar/sr_exhibit/cache"
+)
+
+// entry returns a snapshot entry of a player whose key is also their name
+func entry(player, runID string, place int, primaryT float64) cache.SnapshotEntry {
+	return cache.SnapshotEntry{RunID: runID, PlayerKey: player, PlayerName: player, Place: place, PrimaryT: primaryT}
+}
+
+func TestCompare(t *testing.T) {
+	const board = "Game - Any%"
+	tests := []struct {
+		name  string
+		score bool
+		old   []cache.SnapshotEntry
+		cur   []cache.SnapshotEntry
+		want  []Change
+	}{
+		{
+			name: "unchanged",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 2, 110)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 2, 110)},
+		},
+		{
+			name: "new world record",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 2, 110)},
+			cur:  []cache.SnapshotEntry{entry("bob", "r3", 1, 95), entry("alice", "r1", 2, 100)},
+			want: []Change{
+				{Kind: NewWR, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 100, NewTime: 95, PrevHolder: "alice"},
+				{Kind: TimeSave, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 110, NewTime: 95},
+				{Kind: PlacesGained, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 110, NewTime: 95},
+			},
+		},
+		{
+			name: "time save without moving up",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 2, 110)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r3", 2, 105)},
+			want: []Change{
+				{Kind: TimeSave, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 2, OldTime: 110, NewTime: 105},
+			},
+		},
+		{
+			name: "places gained after a removed run",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 2, 110), entry("carol", "r3", 3, 120)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("carol", "r3", 2, 120)},
+			want: []Change{
+				{Kind: PlacesGained, Board: board, PlayerKey: "carol", PlayerName: "carol", RunID: "r3", OldPlace: 3, NewPlace: 2, OldTime: 120, NewTime: 120},
+			},
+		},
+		{
+			name: "player missing from the old snapshot",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("dave", "r4", 2, 130)},
+			want: []Change{
+				{Kind: NewEntry, Board: board, PlayerKey: "dave", PlayerName: "dave", RunID: "r4", NewPlace: 2, NewTime: 130},
+			},
+		},
+		{
+			name: "new entry taking the record",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100)},
+			cur:  []cache.SnapshotEntry{entry("dave", "r4", 1, 90), entry("alice", "r1", 2, 100)},
+			want: []Change{
+				{Kind: NewWR, Board: board, PlayerKey: "dave", PlayerName: "dave", RunID: "r4", NewPlace: 1, OldTime: 100, NewTime: 90, PrevHolder: "alice"},
+				{Kind: NewEntry, Board: board, PlayerKey: "dave", PlayerName: "dave", RunID: "r4", NewPlace: 1, NewTime: 90},
+			},
+		},
+		{
+			name: "tying the record",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 2, 110)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r3", 1, 100)},
+			want: []Change{
+				{Kind: TimeSave, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 110, NewTime: 100},
+				{Kind: PlacesGained, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 110, NewTime: 100},
+			},
+		},
+		{
+			name: "existing tie",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 1, 100)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("bob", "r2", 1, 100)},
+		},
+		{
+			name: "duplicate runs of a player",
+			old:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("alice", "r0", 2, 105)},
+			cur:  []cache.SnapshotEntry{entry("alice", "r1", 1, 100), entry("alice", "r0", 2, 105)},
+		},
+		{
+			name:  "score gain",
+			score: true,
+			old:   []cache.SnapshotEntry{entry("alice", "r1", 1, 5000), entry("bob", "r2", 2, 4000)},
+			cur:   []cache.SnapshotEntry{entry("bob", "r3", 1, 6000), entry("alice", "r1", 2, 5000)},
+			want: []Change{
+				{Kind: NewWR, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 5000, NewTime: 6000, PrevHolder: "alice", Score: true},
+				{Kind: TimeSave, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 4000, NewTime: 6000, Score: true},
+				{Kind: PlacesGained, Board: board, PlayerKey: "bob", PlayerName: "bob", RunID: "r3", OldPlace: 2, NewPlace: 1, OldTime: 4000, NewTime: 6000, Score: true},
+			},
+		},
+		{
+			name:  "lower score",
+			score: true,
+			old:   []cache.SnapshotEntry{entry("alice", "r1", 1, 5000), entry("bob", "r2", 2, 4000)},
+			cur:   []cache.SnapshotEntry{entry("alice", "r1", 1, 5000), entry("bob", "r3", 2, 3000)},
+		},
+	}
+	for _, tt := range tests {
+		old := &cache.Snapshot{Title: board, Score: tt.score, Entries: tt.old}
+		cur := &cache.Snapshot{Title: board, Score: tt.score, Entries: tt.cur}
+		if got := Compare(old, cur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Compare =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompareNil(t *testing.T) {
+	snap := &cache.Snapshot{Entries: []cache.SnapshotEntry{entry("alice", "r1", 1, 100)}}
+	if got := Compare(nil, snap); got != nil {
+		t.Errorf("Compare(nil, snap) = %v, want nil", got)
+	}
+	if got := Compare(snap, nil); got != nil {
+		t.Errorf("Compare(snap, nil) = %v, want nil", got)
+	}
+}
+
+func TestChangeGaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      Change
+		saved  float64
+		places int
+	}{
+		{"time save", Change{OldPlace: 5, NewPlace: 2, OldTime: 110, NewTime: 95.5}, 14.5, 3},
+		{"score gain", Change{OldPlace: 3, NewPlace: 3, OldTime: 4000, NewTime: 4500, Score: true}, 500, 0},
+		{"new entry", Change{NewPlace: 4, NewTime: 120}, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.c.TimeSaved(); got != tt.saved {
+			t.Errorf("%s: TimeSaved = %v, want %v", tt.name, got, tt.saved)
+		}
+		if got := tt.c.PlacesGained(); got != tt.places {
+			t.Errorf("%s: PlacesGained = %v, want %v", tt.name, got, tt.places)
+		}
+	}
+}
+
+func TestBetter(t *testing.T) {
+	tests := []struct {
+		a, b  float64
+		score bool
+		want  bool
+	}{
+		{95, 100, false, true},
+		{100, 95, false, false},
+		{1491.04, 1491.0399999999, false, false}, // Same millisecond
+		{6000, 5000, true, true},
+		{5000, 6000, true, false},
+	}
+	for _, tt := range tests {
+		if got := Better(tt.a, tt.b, tt.score); got != tt.want {
+			t.Errorf("Better(%v, %v, %v) = %v, want %v", tt.a, tt.b, tt.score, got, tt.want)
+		}
+	}
+}
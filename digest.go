@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/diff"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+const (
+	// defaultDigestPeriod is the default digest period (one week)
+	defaultDigestPeriod = 7 * 24 * time.Hour
+	// digestTitle is the digest headline
+	digestTitle = "Record of the Week"
+)
+
+// digest holds the highlights of one period across all archived boards
+type digest struct {
+	From        time.Time
+	To          time.Time
+	NewWR       *diff.Change // Most notable new world record (biggest margin)
+	BiggestSave *diff.Change // Biggest time save by a player, or score gain on score boards
+	MostPlaces  *diff.Change // Most places gained by a player
+}
+
+// empty reports whether nothing notable happened in the period
+func (d *digest) empty() bool {
+	return d.NewWR == nil && d.BiggestSave == nil && d.MostPlaces == nil
+}
+
+// buildDigest compares, for every archived board, the standings at the start of the period with the latest ones
+func buildDigest(snapshots *cache.SnapshotStore, period time.Duration, now time.Time) (*digest, error) {
+	d := &digest{From: now.Add(-period), To: now}
+
+	boards, err := snapshots.Boards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	for _, board := range boards {
+		history, err := snapshots.History(board)
+		if err != nil {
+			return nil, err
+		}
+		old, cur := periodBounds(history, d.From)
+		if old == nil || cur == nil || old == cur {
+			continue
+		}
+
+		for _, c := range diff.Compare(old, cur) {
+			c := c
+			switch c.Kind {
+			case diff.NewWR:
+				if d.NewWR == nil || c.TimeSaved() > d.NewWR.TimeSaved() {
+					d.NewWR = &c
+				}
+			case diff.TimeSave:
+				if d.BiggestSave == nil || c.TimeSaved() > d.BiggestSave.TimeSaved() {
+					d.BiggestSave = &c
+				}
+			case diff.PlacesGained:
+				if d.MostPlaces == nil || c.PlacesGained() > d.MostPlaces.PlacesGained() {
+					d.MostPlaces = &c
+				}
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// periodBounds returns the baseline snapshot (latest one taken before the period, or the first one
+// inside it) and the latest snapshot. Returns nil if the board has no snapshot inside the period.
+func periodBounds(history []*cache.Snapshot, from time.Time) (old, cur *cache.Snapshot) {
+	if len(history) == 0 {
+		return nil, nil
+	}
+	cur = history[len(history)-1]
+	if cur.TakenAt.Before(from) {
+		return nil, nil
+	}
+	for _, snap := range history {
+		if snap.TakenAt.After(from) {
+			if old == nil {
+				old = snap
+			}
+			break
+		}
+		old = snap
+	}
+	return old, cur
+}
+
+// lines returns the digest highlights as plain sentences
+// Highlights about the run that set the new world record are folded into the record line
+func (d *digest) lines(opts timefmt.Options) []string {
+	var lines []string
+	wrRun := ""
+	if c := d.NewWR; c != nil {
+		wrRun = c.RunID
+		line := fmt.Sprintf("New world record! %s set %s in %s", c.PlayerName, changeValue(*c, opts), c.Board)
+		if c.PrevHolder != "" {
+			line += fmt.Sprintf(", beating the previous record by %s (%s)", c.PrevHolder, changeGap(*c, opts))
+		}
+		lines = append(lines, line+".")
+	}
+	if c := d.BiggestSave; c != nil && c.RunID != wrRun {
+		label := "Biggest time save"
+		if c.Score {
+			label = "Biggest score gain"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s improved by %s to %s in %s (now #%d).",
+			label, c.PlayerName, changeGap(*c, opts), changeValue(*c, opts), c.Board, c.NewPlace))
+	}
+	if c := d.MostPlaces; c != nil && c.RunID != wrRun {
+		places := "places"
+		if c.PlacesGained() == 1 {
+			places = "place"
+		}
+		lines = append(lines, fmt.Sprintf("Most places gained: %s climbed %d %s to #%d in %s.",
+			c.PlayerName, c.PlacesGained(), places, c.NewPlace, c.Board))
+	}
+	return lines
+}
+
+// heading returns the digest title with its period
+func (d *digest) heading() string {
+	return fmt.Sprintf("%s: %s to %s", digestTitle, d.From.Format("2006-01-02"), d.To.Format("2006-01-02"))
+}
+
+// renderMarkdown renders the digest as a Markdown blurb
+func (d *digest) renderMarkdown(opts timefmt.Options) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n", d.heading())
+	if d.empty() {
+		b.WriteString("No leaderboard changes in this period.\n")
+		return b.Bytes()
+	}
+	for _, line := range d.lines(opts) {
+		fmt.Fprintf(&b, "- %s\n", line)
+	}
+	return b.Bytes()
+}
+
+// renderHTML renders the digest as a small standalone HTML announcement
+func (d *digest) renderHTML(opts timefmt.Options) []byte {
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"UTF-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(d.heading()))
+	b.WriteString("<style>body{font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,sans-serif;background:#1a1a2e;color:#eee;padding:24px}h1{color:#64ffda;font-size:1.5rem}li{margin:8px 0}</style>\n")
+	b.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(d.heading()))
+	if d.empty() {
+		b.WriteString("<p>No leaderboard changes in this period.</p>\n")
+	} else {
+		b.WriteString("<ul>\n")
+		for _, line := range d.lines(opts) {
+			fmt.Fprintf(&b, "<li>%s</li>\n", html.EscapeString(line))
+		}
+		b.WriteString("</ul>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
+
+// rssFeed is the minimal RSS 2.0 document structure
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+}
+
+// renderRSS renders the digest as an RSS feed with one item
+func (d *digest) renderRSS(opts timefmt.Options) ([]byte, error) {
+	description := "No leaderboard changes in this period."
+	if !d.empty() {
+		description = strings.Join(d.lines(opts), "\n")
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       digestTitle,
+			Link:        "https://www.speedrun.com",
+			Description: "Weekly highlights generated by sr_exhibit",
+			Items: []rssItem{{
+				Title:       d.heading(),
+				Description: description,
+				PubDate:     d.To.Format(time.RFC1123Z),
+				GUID:        "sr_exhibit-digest-" + d.To.UTC().Format("20060102"),
+			}},
+		},
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize RSS: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// runDigest builds the digest of the period ending at the time of clk and writes it to outputPath, or stdout if empty
+func runDigest(snapshots *cache.SnapshotStore, period time.Duration, format, outputPath string, opts timefmt.Options, clk clock.Clock) error {
+	d, err := buildDigest(snapshots, period, clk.Now())
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	switch format {
+	case "markdown", "md":
+		content = d.renderMarkdown(opts)
+	case "html":
+		content = d.renderHTML(opts)
+	case "rss":
+		content, err = d.renderRSS(opts)
+		if err != nil {
+			return err
+		}
+	default:
+		return withExitCode(exitConfig, fmt.Errorf("unknown digest format: %s (use markdown, html or rss)", format))
+	}
+
+	if outputPath == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(outputPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write digest: %w", err)
+	}
+	fmt.Printf("✓ Digest written to %s\n", outputPath)
+	return nil
+}
+
+// changeValue formats the new time of a change, or its new score on score boards
+func changeValue(c diff.Change, opts timefmt.Options) string {
+	if c.Score {
+		return timefmt.FormatScore(c.NewTime)
+	}
+	return timefmt.FormatSeconds(c.NewTime, opts)
+}
+
+// changeGap formats the improvement of a change, e.g. "-1:23" for a time save or "+120" for a score gain
+func changeGap(c diff.Change, opts timefmt.Options) string {
+	if c.Score {
+		return timefmt.FormatScoreDelta(c.TimeSaved())
+	}
+	return timefmt.FormatDelta(-c.TimeSaved(), opts)
+}
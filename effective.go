@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/soar/sr_exhibit/api"
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/publish"
+	"github.com/soar/sr_exhibit/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// effectiveConfig returns the config as a run with the same arguments uses it: included files merged,
+// command line overrides applied and every default filled in
+// Feeding the result back with --config reproduces the run
+func effectiveConfig(config models.Config, cacheDir, subcategory string, varFilters map[string]string) models.Config {
+	if subcategory != "" {
+		config.Subcategory = subcategory
+	}
+	if len(varFilters) > 0 {
+		config.Variables = varFilters
+	}
+
+	if config.API.BaseURL == "" {
+		config.API.BaseURL = api.DefaultBaseURL
+	}
+	if config.API.Retries == nil {
+		retries := defaultRetries
+		config.API.Retries = &retries
+	}
+	config.Cache.Dir = cacheDir
+	if config.Cache.Fallback == "" {
+		config.Cache.Fallback = fallbackOn
+	}
+
+	if config.Display.StaleAfter == "" {
+		config.Display.StaleAfter = defaultStaleAfter.String()
+	}
+	if config.Display.Ranking == "" {
+		config.Display.Ranking = generator.RankingStandard
+	}
+	if config.Display.Theme == "" {
+		config.Display.Theme = generator.DefaultTheme
+	}
+	if config.Display.Locale == "" {
+		config.Display.Locale = generator.DefaultLocale
+	}
+	if config.Display.Flags == "" {
+		config.Display.Flags = generator.FlagsSprite
+	}
+	if config.Minify.JSVersion == 0 {
+		config.Minify.JSVersion = generator.DefaultJSVersion
+	}
+	if config.Storage.Type == "" {
+		config.Storage.Type = storage.TypeFS
+	}
+
+	if len(config.Leaderboards) > 0 {
+		config.Defaults.OutputDir = batchOutputDir(config.Defaults)
+		if config.Hub.Output == "" {
+			config.Hub.Output = filepath.Join(config.Defaults.OutputDir, filepath.Base(defaultHubOutput))
+		}
+		if config.Hub.Title == "" {
+			config.Hub.Title = defaultHubTitle
+		}
+		config.Workers = renderWorkers(config)
+	} else if config.Output == "./output" {
+		config.Output = "./output/index.html"
+	}
+
+	if config.Highlights.Enabled {
+		if config.Highlights.Runs == 0 {
+			config.Highlights.Runs = generator.DefaultHighlightRuns
+		}
+		if config.Highlights.Seconds == 0 {
+			config.Highlights.Seconds = generator.DefaultHighlightSeconds
+		}
+	}
+	if config.Publish.IPFS.Enabled && config.Publish.IPFS.API == "" {
+		config.Publish.IPFS.API = publish.DefaultIPFSAPI
+	}
+	if config.Schedule.QuietHours != "" && config.Schedule.Timezone == "" {
+		config.Schedule.Timezone = time.Local.String()
+	}
+	for i := range config.Notifications {
+		if config.Notifications[i].Name == "" {
+			config.Notifications[i].Name = fmt.Sprintf("rule %d", i+1)
+		}
+	}
+	return config
+}
+
+// printConfig prints a config as YAML or JSON
+func printConfig(config models.Config, format string) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "yaml":
+		_, err = os.Stdout.Write(data)
+		return err
+	case "json":
+		// Through YAML, so the keys are the config file keys
+		var doc map[string]any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(out, '\n'))
+		return err
+	default:
+		return withExitCode(exitConfig, fmt.Errorf("unknown --print-config format %q (use yaml or json)", format))
+	}
+}
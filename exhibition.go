@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/soar/sr_exhibit/collation"
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// applyExhibition expands the exhibition block into the batch config it stands for: its boards become the
+// leaderboards, its dates an event, and the hub page its landing page with search and highlight reels enabled
+// Without an exhibition the config is returned unchanged
+func applyExhibition(config models.Config) (models.Config, error) {
+	ex := config.Exhibition
+	if !ex.Enabled() {
+		return config, nil
+	}
+	if ex.Name == "" {
+		return config, fmt.Errorf("exhibition.name is required")
+	}
+	if ex.Start == "" || ex.End == "" {
+		return config, fmt.Errorf("exhibition.start and exhibition.end are required")
+	}
+	// The boards are listed in one place, an expanded config (see --print-config) keeps them in leaderboards
+	switch {
+	case len(ex.Boards) > 0 && len(config.Leaderboards) > 0:
+		return config, fmt.Errorf("exhibition.boards can't be combined with leaderboards, list the boards in exhibition.boards")
+	case len(ex.Boards) > 0:
+		config.Leaderboards = ex.Boards
+		config.Exhibition.Boards = nil
+	case len(config.Leaderboards) == 0:
+		return config, fmt.Errorf("exhibition.boards must list at least one board")
+	}
+	if ex.Branding.Logo != "" {
+		if _, err := generator.ImageDataURI(ex.Branding.Logo); err != nil {
+			return config, fmt.Errorf("exhibition.branding.logo: %w", err)
+		}
+	}
+
+	event := models.EventConfig{Name: ex.Name, Start: ex.Start, End: ex.End}
+	listed := false
+	for _, e := range config.Events {
+		listed = listed || e == event
+	}
+	if !listed {
+		config.Events = append(config.Events, event)
+	}
+	if config.Hub.Title == "" {
+		config.Hub.Title = ex.Name
+	}
+	config.Hub.Search = true
+	config.Highlights.Enabled = true
+	if ex.OutputDir != "" {
+		config.Defaults.OutputDir = ex.OutputDir
+	}
+	if ex.Branding.Theme != "" {
+		config.Display.Theme = ex.Branding.Theme
+	}
+	if ex.Branding.AssetsDir != "" {
+		config.AssetsDir = ex.Branding.AssetsDir
+	}
+	return config, nil
+}
+
+// featuredMatcher finds the featured players of an exhibition among the players of the runs
+// Featured players are given as user IDs or names, names match as in the hub search (see collation.Fold)
+type featuredMatcher struct {
+	ids   map[string]int // Index of the entry keyed by user ID
+	names map[string]int // Index of the entry keyed by folded name
+}
+
+// newFeaturedMatcher returns the matcher of the featured players of an exhibition
+func newFeaturedMatcher(entries []string) *featuredMatcher {
+	m := &featuredMatcher{ids: make(map[string]int), names: make(map[string]int)}
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if _, ok := m.ids[entry]; !ok && entry != "" {
+			m.ids[entry] = i
+		}
+		if key := collation.Fold(entry); key != "" {
+			if _, ok := m.names[key]; !ok {
+				m.names[key] = i
+			}
+		}
+	}
+	return m
+}
+
+// match returns the index of the entry matching a run player and the player's display name, -1 if none does
+func (m *featuredMatcher) match(p models.Player, players map[string]models.PlayerData) (int, string) {
+	name := p.Name
+	if p.Rel == "user" {
+		name = players[p.ID].Names.International
+		if i, ok := m.ids[p.ID]; ok {
+			if name == "" {
+				name = p.ID
+			}
+			return i, name
+		}
+	}
+	if name == "" {
+		return -1, ""
+	}
+	if i, ok := m.names[collation.Fold(name)]; ok {
+		return i, name
+	}
+	return -1, ""
+}
+
+// featuredRuns returns the IDs of the runs with a featured player, nil if none has one
+func featuredRuns(m *featuredMatcher, runs []models.RunEntry, players map[string]models.PlayerData) map[string]bool {
+	var featured map[string]bool
+	for _, e := range runs {
+		for _, p := range e.Run.Players {
+			if i, _ := m.match(p, players); i >= 0 {
+				if featured == nil {
+					featured = make(map[string]bool)
+				}
+				featured[e.Run.ID] = true
+				break
+			}
+		}
+	}
+	return featured
+}
+
+// exhibitionFeatured collects the best place of the featured players on each board of the landing page
+// Players are listed in config order, those found on no board are left out
+type exhibitionFeatured struct {
+	matcher *featuredMatcher
+	players []generator.FeaturedPlayer // One per entry
+}
+
+// newExhibitionFeatured returns an empty collection of the featured players of an exhibition
+func newExhibitionFeatured(entries []string) *exhibitionFeatured {
+	return &exhibitionFeatured{matcher: newFeaturedMatcher(entries), players: make([]generator.FeaturedPlayer, len(entries))}
+}
+
+// add records the places of the featured players on a board, whose runs are ordered by place
+func (f *exhibitionFeatured) add(board generator.HubBoard, runs []models.RunEntry) {
+	seen := make(map[int]bool)
+	for _, e := range runs {
+		for _, p := range e.Run.Players {
+			i, name := f.matcher.match(p, board.Players)
+			if i < 0 || seen[i] {
+				continue
+			}
+			seen[i] = true
+			if f.players[i].Name == "" {
+				f.players[i].Name = name
+			}
+			f.players[i].Places = append(f.players[i].Places, generator.FeaturedPlace{Board: board.Title(), Link: board.Link, Place: e.Place})
+		}
+	}
+}
+
+// list returns the featured players found on at least one board
+func (f *exhibitionFeatured) list() []generator.FeaturedPlayer {
+	var found []generator.FeaturedPlayer
+	for _, p := range f.players {
+		if len(p.Places) > 0 {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// newExhibition returns the event of the landing page and podium cards, nil without an exhibition
+func newExhibition(config models.Config) *generator.Exhibition {
+	ex := config.Exhibition
+	if !ex.Enabled() {
+		return nil
+	}
+	event := &generator.Exhibition{Name: ex.Name, Start: ex.Start, End: ex.End}
+	if ex.Branding.Logo != "" {
+		event.Logo, _ = generator.ImageDataURI(ex.Branding.Logo) // Validated at startup
+	}
+	return event
+}
+
+// writePodium writes the podium card of a board, the top places for stream overlays, in an exhibition
+func (s *session) writePodium(gen *generator.Generator, config models.Config, event *generator.Exhibition, board *boardResult, outputPath string) error {
+	if event == nil {
+		return nil
+	}
+	ranking, _ := generator.NewRanking(config.Display) // Validated at startup
+	runs := ranking.Apply(topRuns(board.Leaderboard.Runs, generator.PodiumPlaces), board.Leaderboard.Players.M)
+	data := &generator.PodiumData{
+		Event:       event,
+		Game:        *board.Game,
+		Category:    *board.Category,
+		Subcategory: board.Subcategory,
+		Runs:        runs,
+		Players:     board.Leaderboard.Players.M,
+		Featured:    featuredRuns(newFeaturedMatcher(config.Exhibition.Players), runs, board.Leaderboard.Players.M),
+		HideRanks:   ranking.Hide,
+		Score:       board.Score,
+	}
+	if err := gen.GeneratePodium(podiumPath(outputPath), data); err != nil {
+		return withExitCode(exitGeneration, fmt.Errorf("failed to generate podium card: %w", err))
+	}
+	s.pages++
+	return nil
+}
+
+// podiumPath returns the path of the podium card of a page, e.g. "output/sms-any.html" -> "output/sms-any-podium.html"
+func podiumPath(pagePath string) string {
+	ext := filepath.Ext(pagePath)
+	return strings.TrimSuffix(pagePath, ext) + "-podium" + ext
+}
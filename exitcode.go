@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/soar/sr_exhibit/api"
+)
+
+// Process exit codes, documented in README.md
+const (
+	exitOK           = 0
+	exitFailure      = 1  // Unclassified failure
+	exitConfig       = 2  // Invalid config file or command line arguments
+	exitAPI          = 3  // speedrun.com API request failed
+	exitRateLimited  = 4  // speedrun.com API rate limit reached (HTTP 429)
+	exitCacheOnly    = 5  // Cache-only run (--use-cache) could not load the cache
+	exitGeneration   = 6  // Template or page generation failed
+	exitPartialBatch = 7  // Batch mode: some leaderboards failed, the others were generated
+	exitLocked       = 8  // Another run held the cache directory lock until the wait timed out
+	exitStale        = 9  // Pages were generated from cached data after a failed fetch (cache.fallback: stale)
+	exitPublish      = 10 // Pages were generated but publishing them (publish) failed
+)
+
+// exitError attaches an exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode attaches an exit code to an error, returns nil if err is nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code of an error
+// The innermost code wins, so lower layers can classify errors more precisely
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	code := exitFailure
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if ee, ok := e.(*exitError); ok {
+			code = ee.code
+		}
+	}
+	if code == exitAPI && isRateLimited(err) {
+		return exitRateLimited
+	}
+	return code
+}
+
+// isRateLimited reports whether an API error was caused by the rate limit
+func isRateLimited(err error) bool {
+	var limited *api.ErrRateLimited
+	return errors.As(err, &limited)
+}
+
+// apiExitCode classifies an API client error
+// Names that match no game, category or subcategory value, or several, are config errors, not API failures
+func apiExitCode(err error) int {
+	if errors.Is(err, api.ErrNotFound) || errors.Is(err, api.ErrAmbiguous) {
+		return exitConfig
+	}
+	return exitAPI
+}
+
+// exitWithError prints an error and exits with its exit code
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(exitCode(err))
+}
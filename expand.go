@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/models"
+)
+
+const (
+	// allCategories is the category of a batch entry standing for every full-game category of its game
+	allCategories = "*"
+	// allValues is the subcategory of a batch entry standing for every subcategory value of its category
+	allValues = "*"
+)
+
+// expandCategories replaces the entries with category "*" by one entry per full-game category of their game
+// The expanded entries use the default subcategory values, so categories added on speedrun.com
+// appear without config edits. Entries that can't be expanded are reported through fail.
+func (s *session) expandCategories(ctx context.Context, entries []models.LeaderboardConfig, fail func(code int)) []models.LeaderboardConfig {
+	expanded := make([]models.LeaderboardConfig, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Category != allCategories {
+			expanded = append(expanded, entry)
+			continue
+		}
+		if (entry.Subcategory != "" && entry.Subcategory != allValues) || len(entry.Variables) > 0 || entry.Output != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: subcategory, variables and output can't be set with category \"%s\"\n", entry.Game, allCategories, allCategories)
+			fail(exitConfig)
+			continue
+		}
+
+		fmt.Printf("\nListing categories: %s\n", entry.Game)
+		game, err := s.client.SearchGameByName(ctx, entry.Game)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: failed to search game: %v\n", entry.Game, allCategories, err)
+			fail(apiExitCode(err))
+			continue
+		}
+		categories, err := s.client.GetCategories(ctx, game.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: failed to get categories: %v\n", entry.Game, allCategories, err)
+			fail(exitAPI)
+			continue
+		}
+		count := 0
+		for _, category := range categories {
+			if category.Type != "per-game" {
+				continue
+			}
+			e := entry
+			e.Category = category.Name
+			expanded = append(expanded, e)
+			count++
+		}
+		fmt.Printf("  Found %d full-game categories\n", count)
+	}
+	return expanded
+}
+
+// expandSubcategories replaces the entries with subcategory "*" by one entry per subcategory value of their category
+// With several subcategory variables, every combination of their values gets an entry.
+// Values not seen by earlier runs are reported, their boards are generated from now on.
+func (s *session) expandSubcategories(ctx context.Context, entries []models.LeaderboardConfig, known *cache.KnownValues, fail func(code int)) []models.LeaderboardConfig {
+	expanded := make([]models.LeaderboardConfig, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Subcategory != allValues {
+			expanded = append(expanded, entry)
+			continue
+		}
+		if len(entry.Variables) > 0 || entry.Output != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: variables and output can't be set with subcategory \"%s\"\n", entry.Game, entry.Category, allValues)
+			fail(exitConfig)
+			continue
+		}
+
+		fmt.Printf("\nListing subcategories: %s - %s\n", entry.Game, entry.Category)
+		game, err := s.client.SearchGameByName(ctx, entry.Game)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: failed to search game: %v\n", entry.Game, entry.Category, err)
+			fail(apiExitCode(err))
+			continue
+		}
+		category, err := s.client.GetCategoryByName(ctx, game.ID, entry.Category)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: failed to get category: %v\n", entry.Game, entry.Category, err)
+			fail(apiExitCode(err))
+			continue
+		}
+		variables, err := s.client.GetVariables(ctx, game.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s - %s: failed to get variables: %v\n", entry.Game, entry.Category, err)
+			fail(exitAPI)
+			continue
+		}
+
+		combinations := []map[string]string{{}}
+		for _, v := range variables {
+			if !v.IsSubcategory || (v.Category != "" && v.Category != category.ID) {
+				continue
+			}
+			valueIDs := sortedValueIDs(v)
+			for _, id := range known.Update(game.ID+"/"+category.ID+"/"+v.ID, valueIDs) {
+				fmt.Printf("  New subcategory value: %s - %s - %s: %s\n", game.Names.International, category.Name, v.Name, v.Values.Values[id].Label)
+			}
+
+			var next []map[string]string
+			for _, combination := range combinations {
+				for _, id := range valueIDs {
+					vars := make(map[string]string, len(combination)+1)
+					for varID, valID := range combination {
+						vars[varID] = valID
+					}
+					vars[v.ID] = id
+					next = append(next, vars)
+				}
+			}
+			combinations = next
+		}
+
+		for _, vars := range combinations {
+			e := entry
+			e.Subcategory = ""
+			if len(vars) > 0 {
+				e.Variables = vars
+			}
+			expanded = append(expanded, e)
+		}
+		fmt.Printf("  Found %d subcategory combinations\n", len(combinations))
+	}
+	return expanded
+}
+
+// sortedValueIDs returns the value IDs of a variable sorted by label
+func sortedValueIDs(v models.Variable) []string {
+	ids := make([]string, 0, len(v.Values.Values))
+	for id := range v.Values.Values {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return v.Values.Values[ids[i]].Label < v.Values.Values[ids[j]].Label
+	})
+	return ids
+}
@@ -0,0 +1,363 @@
+// Package export builds the JSON files published next to the generated pages, for bots and widgets
+//
+// Every document carries a schema_version. Compatibility rules:
+//   - Adding a field keeps the version; consumers must ignore unknown fields
+//   - Renaming or removing a field, or changing its meaning, increments the version.
+//     The old field is still written for one more version and listed in "deprecated"
+//     with the field replacing it, so consumers can migrate before it disappears
+//   - Decode reads every earlier version, converting it to the current one
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/generator"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/schema"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// SchemaVersion is the version of the documents written by this program
+const SchemaVersion = 1
+
+// ManifestFile is the name of the batch manifest, written next to the hub page
+const ManifestFile = "manifest.json"
+
+// ChangesFile is the name of the batch diff, written next to the hub page
+const ChangesFile = "changes.json"
+
+// PlayersDir is the directory of the player files (<id>.json), next to the pages
+const PlayersDir = "players"
+
+// Deprecations lists deprecated fields of the current version with the field replacing them
+// Written to every document as "deprecated", empty while nothing is deprecated
+var Deprecations = map[string]string{}
+
+// Header is the part shared by all documents
+type Header struct {
+	SchemaVersion int               `json:"schema_version"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+	Deprecated    map[string]string `json:"deprecated,omitempty"` // Deprecated field -> replacement
+}
+
+// newHeader returns the header of a document generated at t
+func newHeader(t time.Time) Header {
+	h := Header{SchemaVersion: SchemaVersion, GeneratedAt: t.UTC()}
+	if len(Deprecations) > 0 {
+		h.Deprecated = Deprecations
+	}
+	return h
+}
+
+// Board is the JSON export of one leaderboard
+type Board struct {
+	Header
+	Game        Game       `json:"game"`
+	Category    Category   `json:"category"`
+	Subcategory string     `json:"subcategory,omitempty"` // Subcategory labels, e.g. "GCN"
+	Timing      string     `json:"timing,omitempty"`      // Timing method, empty for the game's primary timing
+	DataAsOf    *time.Time `json:"data_as_of,omitempty"`  // Fetch time of the data when it came from the cache
+	Score       bool       `json:"score,omitempty"`       // Values are scores: time_seconds holds the score, time the formatted score
+	Runs        []Run      `json:"runs"`
+}
+
+// Game identifies the game of a board
+type Game struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Abbreviation string `json:"abbreviation,omitempty"`
+	Weblink      string `json:"weblink,omitempty"`
+}
+
+// Category identifies the category of a board
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Run is one run of a board
+type Run struct {
+	Place       int      `json:"place,omitempty"` // Omitted when ranks are hidden (display.hideRanks)
+	ID          string   `json:"id"`
+	Players     []Player `json:"players"`
+	TimeSeconds float64  `json:"time_seconds"`
+	Time        string   `json:"time"` // Time as displayed on the page, e.g. "1:23.45"
+	Date        string   `json:"date,omitempty"`
+	Video       string   `json:"video,omitempty"`
+	Weblink     string   `json:"weblink,omitempty"` // Run page on speedrun.com
+}
+
+// Player is a player of a run
+type Player struct {
+	ID      string `json:"id,omitempty"` // Empty for guests
+	Name    string `json:"name"`
+	Country string `json:"country,omitempty"` // ISO Alpha-2 code, lowercase
+	Guest   bool   `json:"guest,omitempty"`
+	Weblink string `json:"weblink,omitempty"` // Profile on speedrun.com, omitted for guests
+	// No player data when generated (failed fetch, deleted account), the name is "Unknown"
+	Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// Manifest lists the boards of a batch
+type Manifest struct {
+	Header
+	Title  string          `json:"title"`
+	Boards []ManifestBoard `json:"boards"`
+}
+
+// ManifestBoard is one board listed in the manifest
+type ManifestBoard struct {
+	Game        Game     `json:"game"`
+	Category    Category `json:"category"`
+	Subcategory string   `json:"subcategory,omitempty"`
+	Page        string   `json:"page"` // Page path relative to the manifest
+	JSON        string   `json:"json"` // Board JSON path relative to the manifest
+	RunCount    int      `json:"run_count"`
+	// Runs shown with placeholders for players whose data is unavailable, omitted if none
+	Degraded []DegradedRun `json:"degraded,omitempty"`
+}
+
+// DegradedRun is a run shown with placeholders for players whose data is unavailable
+type DegradedRun struct {
+	RunID   string   `json:"run_id"`
+	Place   int      `json:"place,omitempty"`
+	Players []string `json:"players"` // User IDs without data
+}
+
+// Changes lists what a batch changed versus the previous one, for CI pipelines
+// Pages are paths relative to the file, like in the manifest
+type Changes struct {
+	Header
+	Since   *time.Time   `json:"since,omitempty"` // Generation time of the previous batch, omitted for the first one
+	Changed bool         `json:"changed"`         // Any page added, updated or removed
+	Added   []string     `json:"added"`           // Boards not in the previous batch
+	Updated []string     `json:"updated"`         // Boards whose runs changed: runs added, removed or reordered
+	Removed []string     `json:"removed"`         // Boards no longer generated
+	Runs    []ChangedRun `json:"runs"`            // Runs added to boards of the previous batch
+}
+
+// ChangedRun is a run added to a board since the previous batch
+type ChangedRun struct {
+	Page string `json:"page"`
+	Run
+}
+
+// NewChanges creates an empty batch diff
+func NewChanges(generatedAt time.Time) *Changes {
+	return &Changes{Header: newHeader(generatedAt), Added: []string{}, Updated: []string{}, Removed: []string{}, Runs: []ChangedRun{}}
+}
+
+// PlayerFile is the JSON export of one player: players/<id>.json next to the pages of the boards they are on
+type PlayerFile struct {
+	Header
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Country string       `json:"country,omitempty"` // ISO Alpha-2 code, lowercase
+	Style   *PlayerStyle `json:"style,omitempty"`   // Name colors on speedrun.com, omitted if none
+	Weblink string       `json:"weblink,omitempty"` // Profile on speedrun.com
+	Runs    []PlayerRun  `json:"runs"`
+}
+
+// PlayerStyle is the name style of a player, colors as "#rrggbb"
+type PlayerStyle struct {
+	Style     string `json:"style"` // "solid" or "gradient"
+	FromLight string `json:"from_light,omitempty"`
+	FromDark  string `json:"from_dark,omitempty"`
+	ToLight   string `json:"to_light,omitempty"` // Gradients only
+	ToDark    string `json:"to_dark,omitempty"`  // Gradients only
+}
+
+// PlayerRun is a run of the player, with the board it is on
+type PlayerRun struct {
+	Game        Game     `json:"game"`
+	Category    Category `json:"category"`
+	Subcategory string   `json:"subcategory,omitempty"`
+	Timing      string   `json:"timing,omitempty"`
+	Score       bool     `json:"score,omitempty"`
+	Page        string   `json:"page"` // Board page path relative to the player file
+	Run
+}
+
+// NewPlayerFile creates the export of a player without runs, see AddRuns
+func NewPlayerFile(id string, data models.PlayerData, generatedAt time.Time) *PlayerFile {
+	p := newPlayer(models.Player{Rel: "user", ID: id}, map[string]models.PlayerData{id: data})
+	f := &PlayerFile{Header: newHeader(generatedAt), ID: id, Name: p.Name, Country: p.Country, Weblink: p.Weblink, Runs: []PlayerRun{}}
+	if ns := data.NameStyle; ns != nil && ns.Style != "" {
+		f.Style = &PlayerStyle{Style: ns.Style}
+		if ns.ColorFrom != nil {
+			f.Style.FromLight, f.Style.FromDark = ns.ColorFrom.Light, ns.ColorFrom.Dark
+		}
+		if ns.ColorTo != nil {
+			f.Style.ToLight, f.Style.ToDark = ns.ColorTo.Light, ns.ColorTo.Dark
+		}
+	}
+	return f
+}
+
+// AddRuns adds the runs of the player on a board; page is the board page relative to the player file
+func (f *PlayerFile) AddRuns(b *Board, page string) {
+	for _, run := range b.Runs {
+		for _, p := range run.Players {
+			if p.ID == f.ID {
+				f.Runs = append(f.Runs, PlayerRun{
+					Game:        b.Game,
+					Category:    b.Category,
+					Subcategory: b.Subcategory,
+					Timing:      b.Timing,
+					Score:       b.Score,
+					Page:        page,
+					Run:         run,
+				})
+				break
+			}
+		}
+	}
+}
+
+// NewBoard builds the export of a leaderboard
+// timeFormat formats the displayed times; dataAsOf is zero for freshly fetched data
+func NewBoard(game models.Game, category models.Category, subcategory, timing string, lb *models.LeaderboardData, timeFormat timefmt.Options, generatedAt, dataAsOf time.Time) *Board {
+	b := &Board{
+		Header:      newHeader(generatedAt),
+		Game:        NewGame(game),
+		Category:    Category{ID: category.ID, Name: category.Name},
+		Subcategory: subcategory,
+		Timing:      timing,
+		Runs:        make([]Run, 0, len(lb.Runs)),
+	}
+	if !dataAsOf.IsZero() {
+		asOf := dataAsOf.UTC()
+		b.DataAsOf = &asOf
+	}
+	for _, entry := range lb.Runs {
+		run := Run{
+			Place:       entry.Place,
+			ID:          entry.Run.ID,
+			TimeSeconds: float64(timefmt.Millis(entry.Run.Times.PrimaryT)) / 1000,
+			Time:        timefmt.FormatSeconds(entry.Run.Times.PrimaryT, timeFormat),
+			Date:        entry.Run.Date,
+			Video:       generator.GetValidVideoURI(entry.Run),
+			Weblink:     entry.Run.URL(game),
+			Players:     make([]Player, 0, len(entry.Run.Players)),
+		}
+		for _, p := range entry.Run.Players {
+			run.Players = append(run.Players, newPlayer(p, lb.Players.M))
+		}
+		b.Runs = append(b.Runs, run)
+	}
+	return b
+}
+
+// UseScores marks the board as score-based, formatting the values as scores instead of times
+func (b *Board) UseScores() {
+	b.Score = true
+	for i := range b.Runs {
+		b.Runs[i].Time = timefmt.FormatScore(b.Runs[i].TimeSeconds)
+	}
+}
+
+// NewGame converts a game to its export
+func NewGame(game models.Game) Game {
+	return Game{ID: game.ID, Name: game.Names.International, Abbreviation: game.Abbreviation, Weblink: game.URL()}
+}
+
+// newPlayer converts a run player, looking up user names and countries in players
+func newPlayer(p models.Player, players map[string]models.PlayerData) Player {
+	if p.Rel != "user" {
+		return Player{Name: p.Name, Guest: true}
+	}
+	player := Player{ID: p.ID, Name: "Unknown", Unavailable: true}
+	if data, ok := players[p.ID]; ok && generator.PlayerAvailable(data) {
+		player.Unavailable = false
+		player.Name = generator.GetStyledPlayerName(data).Name
+		player.Weblink = data.URL()
+		if data.Location != nil && data.Location.Country != nil {
+			player.Country = strings.ToLower(data.Location.Country.Code)
+		}
+	}
+	return player
+}
+
+// DegradedRuns lists the runs with users missing from players, in board order
+func DegradedRuns(runs []models.RunEntry, players map[string]models.PlayerData) []DegradedRun {
+	var degraded []DegradedRun
+	for _, run := range runs {
+		var missing []string
+		for _, p := range run.Run.Players {
+			if p.Rel == "user" && !generator.PlayerAvailable(players[p.ID]) {
+				missing = append(missing, p.ID)
+			}
+		}
+		if len(missing) > 0 {
+			degraded = append(degraded, DegradedRun{RunID: run.Run.ID, Place: run.Place, Players: missing})
+		}
+	}
+	return degraded
+}
+
+// NewManifest creates an empty manifest
+func NewManifest(title string, generatedAt time.Time) *Manifest {
+	return &Manifest{Header: newHeader(generatedAt), Title: title, Boards: []ManifestBoard{}}
+}
+
+// Marshal encodes a document as indented JSON
+func Marshal(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// ErrNewerVersion is returned by Decode for documents written by a newer version of the program
+var ErrNewerVersion = errors.New("document has a newer schema version")
+
+// converters upgrade a decoded document from the version of their key to the next one
+// e.g. converters[1] would turn a version 1 document into version 2, renaming fields
+var converters = map[int]func(doc map[string]any) error{}
+
+// Decode reads a board, manifest or player document into v, converting earlier schema versions to the current one
+func Decode(data []byte, v any) error {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	version, ok := doc["schema_version"].(float64)
+	if !ok || version < 1 {
+		return fmt.Errorf("document has no schema_version")
+	}
+	if int(version) > SchemaVersion {
+		return fmt.Errorf("%w %d (supported up to %d)", ErrNewerVersion, int(version), SchemaVersion)
+	}
+	for ver := int(version); ver < SchemaVersion; ver++ {
+		convert, ok := converters[ver]
+		if !ok {
+			return fmt.Errorf("no converter from schema version %d", ver)
+		}
+		if err := convert(doc); err != nil {
+			return fmt.Errorf("failed to convert schema version %d: %w", ver, err)
+		}
+		doc["schema_version"] = ver + 1
+	}
+
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, v)
+}
+
+// Documents lists the JSON documents with their types, documented by --schema
+func Documents() []schema.Root {
+	return []schema.Root{
+		{Name: "<page>.json", Type: reflect.TypeOf(Board{})},
+		{Name: ManifestFile, Type: reflect.TypeOf(Manifest{})},
+		{Name: ChangesFile, Type: reflect.TypeOf(Changes{})},
+		{Name: PlayersDir + "/<id>.json", Type: reflect.TypeOf(PlayerFile{})},
+	}
+}
@@ -0,0 +1,201 @@
+package generator
+
+import (
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed assets
+var assetsFS embed.FS
+
+const (
+	// DefaultTheme is the theme used when none is configured
+	DefaultTheme = "dark"
+	// DefaultLocale is the locale used when none is configured, and the fallback for missing strings
+	DefaultLocale = "en"
+	// assetsRoot is the root of the embedded assets
+	assetsRoot = "assets"
+)
+
+// Assets resolves asset files: files in the override directory take priority over the embedded defaults
+type Assets struct {
+	dir string // Override directory, same layout as the embedded assets (see ExportAssets)
+}
+
+// NewAssets creates an asset resolver
+// dir: override directory, embedded assets only if empty
+func NewAssets(dir string) *Assets {
+	return &Assets{dir: dir}
+}
+
+// ReadFile reads an asset by its slash-separated name, e.g. "themes/dark.css"
+func (a *Assets) ReadFile(name string) ([]byte, error) {
+	if a.dir != "" {
+		data, err := os.ReadFile(filepath.Join(a.dir, filepath.FromSlash(name)))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read asset %s: %w", name, err)
+		}
+	}
+	data, err := assetsFS.ReadFile(path.Join(assetsRoot, name))
+	if err != nil {
+		return nil, fmt.Errorf("asset not found: %s", name)
+	}
+	return data, nil
+}
+
+// DataURI reads an asset and returns it as a data URI, so pages need no external files
+func (a *Assets) DataURI(name string) (string, error) {
+	data, err := a.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	mimeType := mime.TypeByExtension(path.Ext(name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Theme returns the CSS of a theme, appended to the built-in page style
+func (a *Assets) Theme(name string) (string, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	data, err := a.ReadFile("themes/" + name + ".css")
+	if err != nil {
+		return "", fmt.Errorf("unknown theme %q: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// Locale returns the UI strings of a locale, completed with the default locale's strings
+// The embedded default strings come first, so locale files exported by older versions stay usable
+func (a *Assets) Locale(name string) (map[string]string, error) {
+	texts := make(map[string]string)
+	embedded, err := assetsFS.ReadFile(path.Join(assetsRoot, "locales", DefaultLocale+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if err := parseLocale(embedded, texts); err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = DefaultLocale
+	}
+	data, err := a.ReadFile("locales/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown locale %q: %w", name, err)
+	}
+	if err := parseLocale(data, texts); err != nil {
+		return nil, fmt.Errorf("failed to parse locale %s: %w", name, err)
+	}
+	return texts, nil
+}
+
+// Flags returns the SVG country flags keyed by file name without extension, e.g. "us" or "gb-eng"
+// Flags in the override directory replace or extend the embedded ones
+func (a *Assets) Flags() (map[string][]byte, error) {
+	flags := make(map[string][]byte)
+	entries, err := assetsFS.ReadDir(path.Join(assetsRoot, "flags"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if path.Ext(name) != ".svg" {
+			continue
+		}
+		data, err := assetsFS.ReadFile(path.Join(assetsRoot, "flags", name))
+		if err != nil {
+			return nil, err
+		}
+		flags[strings.TrimSuffix(name, ".svg")] = data
+	}
+
+	if a.dir == "" {
+		return flags, nil
+	}
+	dir := filepath.Join(a.dir, "flags")
+	overrides, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return flags, nil
+		}
+		return nil, fmt.Errorf("failed to read flags: %w", err)
+	}
+	for _, entry := range overrides {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".svg" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read flag %s: %w", entry.Name(), err)
+		}
+		flags[strings.ToLower(strings.TrimSuffix(entry.Name(), ".svg"))] = data
+	}
+	return flags, nil
+}
+
+// parseLocale parses a locale file into texts, overriding existing keys
+func parseLocale(data []byte, texts map[string]string) error {
+	var parsed map[string]string
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	for key, value := range parsed {
+		texts[key] = value
+	}
+	return nil
+}
+
+// ExportAssets writes the embedded assets to dir for customization
+// Existing files are kept unless overwrite is set; returns the paths of the written files
+func ExportAssets(dir string, overwrite bool) ([]string, error) {
+	var written []string
+	err := fs.WalkDir(assetsFS, assetsRoot, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(assetsRoot, filepath.FromSlash(name))
+		target := filepath.Join(dir, rel)
+		ok, err := exportFile(assetsFS, name, target, overwrite)
+		if ok {
+			written = append(written, target)
+		}
+		return err
+	})
+	return written, err
+}
+
+// exportFile copies one embedded file to disk, returns false if the target exists and overwrite is not set
+func exportFile(fsys fs.FS, name, target string, overwrite bool) (bool, error) {
+	if !overwrite {
+		if _, err := os.Stat(target); err == nil {
+			return false, nil
+		}
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return true, nil
+}
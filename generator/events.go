@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+// eventDateLayout is the date format of event ranges and run dates
+const eventDateLayout = "2006-01-02"
+
+// Event represents a parsed named date range, both days inclusive
+type Event struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// ParseEvents parses the configured event date ranges
+func ParseEvents(events []models.EventConfig) ([]Event, error) {
+	parsed := make([]Event, 0, len(events))
+	for i, e := range events {
+		if e.Name == "" {
+			return nil, fmt.Errorf("events[%d]: missing name", i)
+		}
+		start, err := time.Parse(eventDateLayout, e.Start)
+		if err != nil {
+			return nil, fmt.Errorf("events[%d] (%s): invalid start date %q, expected YYYY-MM-DD", i, e.Name, e.Start)
+		}
+		end, err := time.Parse(eventDateLayout, e.End)
+		if err != nil {
+			return nil, fmt.Errorf("events[%d] (%s): invalid end date %q, expected YYYY-MM-DD", i, e.Name, e.End)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("events[%d] (%s): end date is before start date", i, e.Name)
+		}
+		parsed = append(parsed, Event{Name: e.Name, Start: start, End: end})
+	}
+	return parsed, nil
+}
+
+// TagEvents returns the names of the events each run's date falls in, keyed by run ID
+// Runs without a date or outside every event are left out
+func TagEvents(runs []models.RunEntry, events []Event) map[string][]string {
+	tags := make(map[string][]string)
+	for _, run := range runs {
+		date, err := time.Parse(eventDateLayout, run.Run.Date)
+		if err != nil {
+			continue
+		}
+		for _, e := range events {
+			if !date.Before(e.Start) && !date.After(e.End) {
+				tags[run.Run.ID] = append(tags[run.Run.ID], e.Name)
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+// PodiumPlaces is the number of places on a podium card
+const PodiumPlaces = 3
+
+// Exhibition is the event of an exhibition mini-site, shown on its landing page and podium cards
+type Exhibition struct {
+	Name     string
+	Start    string           // First day, "2006-01-02"
+	End      string           // Last day (inclusive), "2006-01-02"
+	Logo     string           // Logo image as a data URI, empty if none
+	Featured []FeaturedPlayer // Featured players found on the boards, in config order
+}
+
+// FeaturedPlayer is a featured player of an exhibition with their places on its boards
+type FeaturedPlayer struct {
+	Name   string
+	Places []FeaturedPlace // In board order
+}
+
+// FeaturedPlace is the best place of a featured player on a board
+type FeaturedPlace struct {
+	Board string // e.g. "Super Mario Sunshine - Any% (GCN)"
+	Link  string // Page path relative to the landing page
+	Place int    // 0 when ranks are hidden
+}
+
+// PodiumData represents the template data of a podium card, the top places of a board for stream overlays
+type PodiumData struct {
+	Event       *Exhibition // Nil outside an exhibition
+	Game        models.Game
+	Category    models.Category
+	Subcategory string            // Subcategory labels, empty if none
+	Runs        []models.RunEntry // Runs of the podium places
+	Players     map[string]models.PlayerData
+	Featured    map[string]bool // Runs of featured players, keyed by run ID
+	HideRanks   bool            // Don't show places, see Ranking
+	Score       bool            // Values are scores, shown with formatScore instead of as times
+}
+
+// GeneratePodium generates the podium card of a leaderboard
+func (g *Generator) GeneratePodium(outputPath string, data *PodiumData) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.writePage(outputPath, g.podium, "podium.html", data)
+}
+
+// ImageDataURI reads an image file into a data URI, for logos inlined into the pages
+func ImageDataURI(path string) (string, error) {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("%s is not an image (use SVG, PNG, JPEG, GIF or WebP)", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Flag display modes
+const (
+	// FlagsSprite inlines the used flags as an SVG sprite, flags without an SVG file show the placeholder
+	FlagsSprite = "sprite"
+	// FlagsRemote loads flag images from speedrun.com
+	FlagsRemote = "remote"
+)
+
+var (
+	viewBoxPattern = regexp.MustCompile(`viewBox="([^"]*)"`)
+	// idPattern matches element IDs and the references to them inside a flag
+	idPattern = regexp.MustCompile(`(id="|href="#|url\(#)([^")]+)`)
+)
+
+// SetFlagMode selects how country flags are displayed: FlagsSprite (default) or FlagsRemote
+func (g *Generator) SetFlagMode(mode string) error {
+	switch mode {
+	case "":
+		mode = FlagsSprite
+	case FlagsSprite, FlagsRemote:
+	default:
+		return fmt.Errorf("unknown flag mode %q (use %s or %s)", mode, FlagsSprite, FlagsRemote)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flagMode = mode
+	return nil
+}
+
+// flagSymbol converts a standalone SVG file to a <symbol> element with the given ID
+// IDs inside the flag (gradients, clip paths) are prefixed, so flags on one page don't clash
+func flagSymbol(id string, svg []byte) (string, error) {
+	content := strings.TrimSpace(string(svg))
+	start := strings.Index(content, "<svg")
+	end := strings.LastIndex(content, "</svg>")
+	if start < 0 || end < 0 {
+		return "", fmt.Errorf("flag %s is not an SVG file", id)
+	}
+	open := strings.Index(content[start:], ">")
+	if open < 0 || start+open >= end {
+		return "", fmt.Errorf("flag %s is not an SVG file", id)
+	}
+	tag := content[start : start+open]
+	inner := idPattern.ReplaceAllString(content[start+open+1:end], "${1}flag-"+id+"-${2}")
+
+	viewBox := ""
+	if m := viewBoxPattern.FindStringSubmatch(tag); m != nil {
+		viewBox = fmt.Sprintf(` viewBox="%s"`, m[1])
+	}
+	return fmt.Sprintf(`<symbol id="flag-%s"%s>%s</symbol>`, id, viewBox, inner), nil
+}
+
+// flagCode normalizes a country code to a flag asset name
+// e.g. "US" -> "us", "GB/ENG" -> "gb-eng"
+func flagCode(code string) string {
+	return strings.ReplaceAll(strings.ToLower(code), "/", "-")
+}
+
+// flagSet collects the flags used while rendering one page
+type flagSet struct {
+	g    *Generator
+	used map[string]bool
+}
+
+// newFlagSet creates an empty flag collector for one page
+func (g *Generator) newFlagSet() *flagSet {
+	return &flagSet{g: g, used: make(map[string]bool)}
+}
+
+// tag returns the markup of a country flag with the given CSS class
+// In sprite mode a flag with an SVG file references a symbol of the page sprite and other flags show
+// the placeholder, so pages load nothing remotely; in remote mode flags load the speedrun.com image,
+// with the placeholder for codes it doesn't know
+func (s *flagSet) tag(code, class string) string {
+	url := CountryFlagURLWithMap(code, s.g.countryCodeMap)
+	if url == "" {
+		return ""
+	}
+	if s.g.flagMode == FlagsSprite {
+		name := flagCode(mapCountryCode(code, s.g.countryCodeMap))
+		if _, ok := s.g.flags[name]; ok {
+			s.used[name] = true
+			return fmt.Sprintf(`<svg class="%s" role="img" aria-label="%s"><use href="#flag-%s"></use></svg>`, class, code, name)
+		}
+		return fmt.Sprintf(`<img src="%s" alt="%s" title="%s" class="%s">`, s.g.images[flagPlaceholderAsset], code, code, class)
+	}
+	return fmt.Sprintf(`<img src="%s" alt="%s" class="%s" onerror="this.onerror=null;this.src='%s'">`,
+		url, code, class, s.g.images[flagPlaceholderAsset])
+}
+
+// sprite returns the hidden SVG sprite holding the symbols of the used flags
+func (s *flagSet) sprite() string {
+	if len(s.used) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(s.used))
+	for name := range s.used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" style="display:none">`)
+	for _, name := range names {
+		b.WriteString(s.g.flags[name])
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
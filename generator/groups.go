@@ -0,0 +1,192 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/soar/sr_exhibit/collation"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// Run keys of the groupBy, sortBy and filter template functions
+var runKeyNames = []string{"country", "platform", "subcategory", "year", "date", "player", "place", "time"}
+
+// RunGroup is a group of runs sharing a key value, see groupBy
+type RunGroup struct {
+	Key  string            // Key value, e.g. "jp" or "2024"; empty for the runs without one
+	Runs []models.RunEntry // In their order in the input
+}
+
+// runKeys resolves the keys of runs for the run helpers of a page
+type runKeys struct {
+	players        map[string]models.PlayerData
+	platforms      map[string]string // Platform names keyed by ID
+	sources        map[string]string // Merged boards: subcategory each run comes from, keyed by run ID
+	countryCodeMap map[string]string
+	collation      collation.Collation // Order of the text keys in sortBy
+}
+
+// newRunKeys returns the run keys of the page data, with the players and platforms of every board on a hub
+func (g *Generator) newRunKeys(data interface{}) *runKeys {
+	k := &runKeys{countryCodeMap: g.countryCodeMap, collation: g.collation}
+	switch d := data.(type) {
+	case *LeaderboardData:
+		k.players, k.platforms, k.sources = d.Players, d.Leaderboard.Platforms.M, d.Sources
+	case *HubData:
+		k.players = make(map[string]models.PlayerData)
+		for _, b := range d.Boards {
+			for id, pd := range b.Players {
+				k.players[id] = pd
+			}
+		}
+	}
+	return k
+}
+
+// value returns the key value of a run, "" if the run has none
+func (k *runKeys) value(key string, e models.RunEntry) (string, error) {
+	switch key {
+	case "country":
+		for _, p := range e.Run.Players {
+			if pd, ok := k.players[p.ID]; ok && p.Rel == "user" && pd.Location != nil && pd.Location.Country != nil && pd.Location.Country.Code != "" {
+				code := strings.ToLower(pd.Location.Country.Code)
+				if replacement, ok := k.countryCodeMap[code]; ok {
+					code = strings.ToLower(replacement)
+				}
+				return code, nil
+			}
+		}
+		return "", nil
+	case "platform":
+		if name, ok := k.platforms[e.Run.System.Platform]; ok {
+			return name, nil
+		}
+		return e.Run.System.Platform, nil
+	case "subcategory":
+		return k.sources[e.Run.ID], nil
+	case "year":
+		if len(e.Run.Date) < 4 {
+			return "", nil
+		}
+		return e.Run.Date[:4], nil
+	case "date":
+		return e.Run.Date, nil
+	case "player":
+		return runNames(e.Run, k.players), nil
+	case "place":
+		if e.Place == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(e.Place), nil
+	case "time":
+		if e.Run.Times.PrimaryT == 0 {
+			return "", nil
+		}
+		return strconv.FormatFloat(e.Run.Times.PrimaryT, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unknown run key %q (use %s)", key, strings.Join(runKeyNames, ", "))
+}
+
+// groupBy groups runs by a key value, e.g. {{range groupBy "country" .Leaderboard.Runs}}
+// Groups are in the order of their first run, the group of the runs without a value comes last
+func (k *runKeys) groupBy(key string, runs []models.RunEntry) ([]RunGroup, error) {
+	var groups []RunGroup
+	index := make(map[string]int)
+	var rest []models.RunEntry
+	for _, e := range runs {
+		v, err := k.value(key, e)
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			rest = append(rest, e)
+			continue
+		}
+		i, ok := index[v]
+		if !ok {
+			i = len(groups)
+			index[v] = i
+			groups = append(groups, RunGroup{Key: v})
+		}
+		groups[i].Runs = append(groups[i].Runs, e)
+	}
+	if len(rest) > 0 {
+		groups = append(groups, RunGroup{Runs: rest})
+	}
+	return groups, nil
+}
+
+// sortBy returns a copy of the runs sorted by a key value, descending with a "-" prefix (e.g. "-date")
+// Places and times compare as numbers, text in the order of the collation; runs without a value come last
+// and runs with the same value keep their order
+func (k *runKeys) sortBy(key string, runs []models.RunEntry) ([]models.RunEntry, error) {
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+	values := make(map[string]string, len(runs)) // Keyed by run ID
+	for _, e := range runs {
+		v, err := k.value(key, e)
+		if err != nil {
+			return nil, err
+		}
+		values[e.Run.ID] = v
+	}
+
+	compare := strings.Compare
+	switch key {
+	case "place", "time":
+		compare = func(a, b string) int {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case "country", "platform", "subcategory", "player":
+		compare = k.collation.Compare()
+	}
+
+	sorted := make([]models.RunEntry, len(runs))
+	copy(sorted, runs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := values[sorted[i].Run.ID], values[sorted[j].Run.ID]
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		if desc {
+			return compare(a, b) > 0
+		}
+		return compare(a, b) < 0
+	})
+	return sorted, nil
+}
+
+// filter returns the runs whose key value is value, e.g. {{range filter "year" "2024" .Leaderboard.Runs}}
+// An empty value selects the runs without one
+func (k *runKeys) filter(key, value string, runs []models.RunEntry) ([]models.RunEntry, error) {
+	var kept []models.RunEntry
+	for _, e := range runs {
+		v, err := k.value(key, e)
+		if err != nil {
+			return nil, err
+		}
+		if v == value {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+// funcs returns the run helpers bound to the page
+func (k *runKeys) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"groupBy": k.groupBy,
+		"sortBy":  k.sortBy,
+		"filter":  k.filter,
+	}
+}
@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+// Highlight reel defaults (highlights block)
+const (
+	DefaultHighlightRuns    = 20 // Top runs in the rotation, recent runs below them are added
+	DefaultHighlightSeconds = 8  // Seconds each run is shown
+	// highlightRecentDays is how long a new personal best gets a higher weight
+	highlightRecentDays = 30
+)
+
+// youtubeID matches a YouTube video ID
+var youtubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// Highlight represents one run of the highlight reel
+type Highlight struct {
+	Run       models.RunEntry
+	Weight    float64 // Relative chance of being shown next
+	Video     string  // First video link, empty if none
+	Thumbnail string  // Video thumbnail URL, empty for videos other than YouTube
+}
+
+// HighlightsData represents the template data of the highlight reel, a page rotating through featured runs
+// for stream intermissions
+type HighlightsData struct {
+	Game        models.Game
+	Category    models.Category
+	Subcategory string // Subcategory labels, empty if none
+	Runs        []Highlight
+	Players     map[string]models.PlayerData
+	Seconds     int  // Seconds each run is shown
+	HideRanks   bool // Don't show places, see Ranking
+	Score       bool // Values are scores, not times
+}
+
+// NewHighlights picks the runs of the highlight reel: the top runs plus every run done in the last
+// highlightRecentDays. Runs are weighted toward top places (1/place) and recent runs: a run done
+// today weighs three times as much, fading back to normal over highlightRecentDays.
+// runs are ordered by place; runs without a place (hidden ranks) are weighted by position.
+func NewHighlights(runs []models.RunEntry, top int, now time.Time) []Highlight {
+	var highlights []Highlight
+	for i, run := range runs {
+		recent := recency(run.Run.Date, now)
+		if i >= top && recent == 0 {
+			continue
+		}
+		place := run.Place
+		if place == 0 {
+			place = i + 1
+		}
+		h := Highlight{Run: run, Weight: (1 / float64(place)) * (1 + 2*recent)}
+		if run.Run.Videos != nil && len(run.Run.Videos.Links) > 0 {
+			h.Video = run.Run.Videos.Links[0].URI
+			h.Thumbnail = youtubeThumbnail(h.Video)
+		}
+		highlights = append(highlights, h)
+	}
+	return highlights
+}
+
+// recency returns 1 for a run done on now's day, fading linearly to 0 after highlightRecentDays
+func recency(date string, now time.Time) float64 {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+	days := now.Sub(d).Hours() / 24
+	if days >= highlightRecentDays {
+		return 0
+	}
+	if days < 0 {
+		days = 0
+	}
+	return 1 - days/highlightRecentDays
+}
+
+// youtubeThumbnail returns the thumbnail of a YouTube video link, or "" for other links
+func youtubeThumbnail(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	var id string
+	switch strings.TrimPrefix(strings.TrimPrefix(u.Hostname(), "www."), "m.") {
+	case "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case "youtube.com":
+		if u.Path == "/watch" {
+			id = u.Query().Get("v")
+		} else if rest, ok := strings.CutPrefix(u.Path, "/live/"); ok {
+			id = rest
+		} else if rest, ok := strings.CutPrefix(u.Path, "/shorts/"); ok {
+			id = rest
+		}
+	}
+	if !youtubeID.MatchString(id) {
+		return ""
+	}
+	return "https://i.ytimg.com/vi/" + id + "/mqdefault.jpg"
+}
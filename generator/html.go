@@ -1,20 +1,23 @@
 package generator
 
 import (
-	"bytes"
+	"bufio"
 	"embed"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
+	"github.com/soar/sr_exhibit/collation"
 	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/storage"
+	"github.com/soar/sr_exhibit/timefmt"
 	"github.com/tdewolff/minify/v2"
-	"github.com/tdewolff/minify/v2/css"
-	"github.com/tdewolff/minify/v2/html"
-	"github.com/tdewolff/minify/v2/js"
 )
 
 //go:embed *.html
@@ -26,180 +29,541 @@ type LeaderboardData struct {
 	Category       models.Category
 	Leaderboard    models.LeaderboardData
 	Players        map[string]models.PlayerData
-	CountryCodeMap map[string]string // Country code replacement rules
+	CountryCodeMap map[string]string       // Country code replacement rules
+	Movements      map[string]RankMovement // Rank movement since the previous snapshot, keyed by run ID (nil if disabled)
+	Trajectories   map[string]Trajectory   // Standings over the last snapshots, keyed by run ID (nil if disabled)
+	Heat           map[string]float64      // Recent improvement of each run's player from 0 to 1, keyed by run ID (nil if disabled)
+	Featured       map[string]bool         // Runs of featured players (exhibition.players), keyed by run ID
+	WRHolders      map[string][]string     // Player key -> categories where the player holds #1 (batch mode only)
+	ShowGaps       bool                    // Show the "+Gap" column
+	Gaps           map[string]RunGap       // Time gaps keyed by run ID, filled by Generate when ShowGaps is set
+	VisibleRows    int                     // Rows shown before the "Show all" expander, 0 shows every row
+	DataAsOf       time.Time               // Fetch time of stale cached data, shown in a banner; zero if fresh
+	Page           models.PageConfig       // Title, description and header overrides
+	Events         []Event                 // Named date ranges, see ParseEvents
+	EventTags      map[string][]string     // Event names keyed by run ID, filled by Generate when Events is set
+	Sources        map[string]string       // Merged boards: subcategory each run comes from, keyed by run ID
+	HideRanks      bool                    // Don't show places, see Ranking
+	Score          bool                    // Values are scores, shown with formatScore instead of as times
+}
+
+// RunGap represents a run's time difference to the run above and to the world record
+type RunGap struct {
+	Prev    float64 // Seconds behind the run one place above
+	WR      float64 // Seconds behind the world record
+	HasPrev bool    // False for the first run
+}
+
+// HubData represents hub page template data structure
+type HubData struct {
+	Title     string
+	Boards    []HubBoard
+	WRHolders map[string][]string // Player key -> categories where the player holds #1
+	HideRanks bool                // Don't show places, see Ranking
+	Search    *SearchIndex        // Search box index, nil if disabled (hub.search)
+	Event     *Exhibition         // Exhibition event of the landing page, nil outside an exhibition
+}
+
+// HubBoard represents one leaderboard listed on the hub page
+type HubBoard struct {
+	Game        models.Game
+	Category    models.Category
+	Subcategory string // Subcategory label, empty if none
+	Link        string // Page path relative to the hub page
+	Highlights  string // Highlight reel path relative to the hub page, empty if none
+	Podium      string // Podium card path relative to the hub page, empty if none
+	Top         []models.RunEntry
+	RunCount    int
+	Players     map[string]models.PlayerData
+	Score       bool // Values are scores, not times
+}
+
+// Title returns the title of the board, e.g. "Super Mario Sunshine - Any% (GCN)"
+func (b HubBoard) Title() string {
+	title := b.Game.Names.International + " - " + b.Category.Name
+	if b.Subcategory != "" {
+		title += " (" + b.Subcategory + ")"
+	}
+	return title
+}
+
+// RetiredData represents the template data of the page replacing a leaderboard removed upstream
+type RetiredData struct {
+	Title           string
+	GameName        string
+	CategoryName    string
+	Subcategory     string // Subcategory labels, empty if none
+	Link            string // Nearest surviving page, relative to the retired page
+	LinkTitle       string
+	RedirectSeconds int // Delay before redirecting to Link, 0 to only show the notice
+}
+
+// CompareData represents compare page template data structure
+type CompareData struct {
+	Game      models.Game
+	Category  models.Category
+	LabelA    string // First subcategory label, e.g. "PC"
+	LabelB    string // Second subcategory label, e.g. "Console"
+	Rows      []CompareRow
+	Players   map[string]models.PlayerData
+	Summary   CompareSummary
+	HideRanks bool // Don't show places, see Ranking
+	Score     bool // Values are scores, not times
+}
+
+// CompareRow represents one player (or player group) on the compare page
+type CompareRow struct {
+	Players []models.Player
+	RunA    *models.RunEntry // Run on the first board, nil if absent
+	RunB    *models.RunEntry // Run on the second board, nil if absent
+	Delta   float64          // Seconds of RunB minus RunA, valid when both runs exist
+}
+
+// HasBoth reports whether the player has a run on both boards
+func (r CompareRow) HasBoth() bool {
+	return r.RunA != nil && r.RunB != nil
+}
+
+// CompareSummary summarizes the players who have runs on both boards
+type CompareSummary struct {
+	Common      int     // Players with a run on both boards
+	FasterA     int     // Players faster on the first board
+	FasterB     int     // Players faster on the second board
+	MedianDelta float64 // Median of RunB minus RunA
+	MeanDelta   float64 // Mean of RunB minus RunA
+}
+
+// RankMovement represents how a run's place changed since the previous snapshot
+type RankMovement struct {
+	Delta int  // Places gained (positive) or lost (negative)
+	New   bool // Player was not on the previous snapshot
 }
 
 // Generator represents the HTML generator
+// A Generator is safe for concurrent use: pages can be generated in parallel (each rendering executes
+// its own clone of the template) and settings changed in between, a page is rendered with the settings
+// at its start.
 type Generator struct {
+	mu             sync.RWMutex // Held for writing by the Set* methods, for reading while a page is generated
 	templates      *template.Template
+	hub            *template.Template
+	compare        *template.Template
+	retired        *template.Template
+	highlights     *template.Template
+	podium         *template.Template
 	m              *minify.M
-	countryCodeMap map[string]string // Country code replacement rules
+	countryCodeMap map[string]string   // Country code replacement rules
+	timeFormat     timefmt.Options     // Fractional seconds display options
+	themeCSS       string              // Theme CSS appended to the page style
+	texts          map[string]string   // UI strings of the selected locale
+	images         map[string]string   // Embedded images as data URIs, keyed by asset name
+	flags          map[string]string   // Flag sprite symbols, keyed by flag code (see flagCode)
+	flagMode       string              // How country flags are displayed: FlagsSprite or FlagsRemote
+	funcMap        template.FuncMap    // Template functions, shared by all templates
+	collation      collation.Collation // Text order of the sortBy template function
+	storage        storage.Storage     // Where pages are written
 }
 
 // NewGenerator creates a new generator
 // templatePath: use embedded template if empty, otherwise load external template from specified path
 // countryCodeMap: country code replacement rules, e.g. {"xk": "rs"} for Kosovo -> Serbia
 func NewGenerator(templatePath string, countryCodeMap map[string]string) (*Generator, error) {
+	g := &Generator{
+		countryCodeMap: countryCodeMap,
+		flagMode:       FlagsSprite,
+		storage:        storage.FS{},
+	}
+
 	// Create template and register custom functions
 	// Include flagURL with closure over countryCodeMap
 	funcMap := template.FuncMap{
-		"formatTime":    formatTimeISO,
-		"nameStyleAttr": GetNameStyleAttr,
-		"styledName":    GetStyledPlayerName,
-		"first":         firstN,
-		"add":           add,
-		"sub":           sub,
+		"formatTime":     g.formatTimeISO,
+		"formatSeconds":  g.formatSeconds,
+		"formatGap":      g.formatGap,
+		"formatScore":    timefmt.FormatScore,
+		"formatScoreGap": timefmt.FormatScoreDelta,
+		"nameStyleAttr":  GetNameStyleAttr,
+		"styledName":     g.styledName,
+		"first":          firstN,
+		"add":            add,
+		"sub":            sub,
+		"join":           strings.Join,
 		"flagURL": func(code string) string {
 			return CountryFlagURLWithMap(code, countryCodeMap)
 		},
+		"themeCSS":        func() string { return g.themeCSS },
+		"t":               g.text,
+		"flagPlaceholder": func() string { return g.images[flagPlaceholderAsset] },
+		"trophy":          g.trophy,
+		"formatDate":      g.formatDate,
+		"sparkline":       sparkline,
+		"flag":            g.newFlagSet().tag, // Rebound per page by render
+	}
+	for name, f := range g.newRunKeys(nil).funcs() {
+		funcMap[name] = f // Rebound per page by render
 	}
 
-	var tmpl *template.Template
-	var err error
+	g.funcMap = funcMap
 
-	if templatePath != "" {
-		// Load template from external file
-		content, err := os.ReadFile(templatePath)
+	tmpl, err := g.loadTemplate("leaderboard.html", templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hub and compare pages use the embedded templates unless SetHubTemplate or SetCompareTemplate is called
+	hub, err := g.loadTemplate("hub.html", "")
+	if err != nil {
+		return nil, err
+	}
+	compare, err := g.loadTemplate("compare.html", "")
+	if err != nil {
+		return nil, err
+	}
+	retired, err := g.loadTemplate("retired.html", "")
+	if err != nil {
+		return nil, err
+	}
+	highlights, err := g.loadTemplate("highlights.html", "")
+	if err != nil {
+		return nil, err
+	}
+	podium, err := g.loadTemplate("podium.html", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize minifier with the default options, see SetMinify
+	m, err := newMinifier(models.MinifyConfig{})
+	if err != nil {
+		return nil, err
+	}
+
+	g.templates = tmpl
+	g.hub = hub
+	g.compare = compare
+	g.retired = retired
+	g.highlights = highlights
+	g.podium = podium
+	g.m = m
+
+	if err := g.SetAssets(NewAssets(""), DefaultTheme, DefaultLocale); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// loadTemplate parses an external template file, or the embedded template of the same name if path is empty
+func (g *Generator) loadTemplate(name, path string) (*template.Template, error) {
+	if path == "" {
+		tmpl, err := template.New(name).Funcs(g.funcMap).ParseFS(templateFS, name)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, fmt.Errorf("template file not found: %s", templatePath)
-			}
-			if os.IsPermission(err) {
-				return nil, fmt.Errorf("no permission to read template file: %s", templatePath)
-			}
-			return nil, fmt.Errorf("failed to read template file: %w", err)
+			return nil, fmt.Errorf("failed to parse embedded template %s: %w", name, err)
 		}
+		return tmpl, nil
+	}
 
-		tmpl, err = template.New("leaderboard.html").Funcs(funcMap).Parse(string(content))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse external template: %w\nHint: Please check if template syntax is correct", err)
+	// Load template from external file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("template file not found: %s", path)
 		}
-	} else {
-		// Use embedded template
-		tmpl, err = template.New("leaderboard.html").Funcs(funcMap).ParseFS(templateFS, "leaderboard.html")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse embedded template: %w", err)
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("no permission to read template file: %s", path)
 		}
+		return nil, fmt.Errorf("failed to read template file: %w", err)
 	}
 
-	// Initialize minifier
-	m := minify.New()
-	m.Add("text/html", &html.Minifier{
-		KeepDefaultAttrVals: true,
-		KeepDocumentTags:   true,
-		KeepWhitespace:     false,
-	})
-	m.Add("text/css", &css.Minifier{})
-	m.Add("text/javascript", &js.Minifier{
-		KeepVarNames: false,
-		Version:      2022,
-	})
-
-	return &Generator{
-		templates:      tmpl,
-		m:              m,
-		countryCodeMap: countryCodeMap,
-	}, nil
+	tmpl, err := template.New(name).Funcs(g.funcMap).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse external template: %w\nHint: Please check if template syntax is correct", err)
+	}
+	return tmpl, nil
 }
 
-// formatTimeISO formats ISO 8601 duration string (e.g., "PT16M25S") to readable format
-func formatTimeISO(isoTime string) string {
-	// ISO 8601 format: PT16M25S means 16 minutes 25 seconds
-	if len(isoTime) == 0 || isoTime == "PT0S" {
-		return "0:00"
+// SetHubTemplate replaces the embedded hub page template with an external template file
+func (g *Generator) SetHubTemplate(path string) error {
+	hub, err := g.loadTemplate("hub.html", path)
+	if err != nil {
+		return err
 	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hub = hub
+	return nil
+}
 
-	// Parse PT...S format
-	remaining := isoTime
-	if strings.HasPrefix(remaining, "PT") {
-		remaining = remaining[2:]
+// SetCompareTemplate replaces the embedded compare page template with an external template file
+func (g *Generator) SetCompareTemplate(path string) error {
+	compare, err := g.loadTemplate("compare.html", path)
+	if err != nil {
+		return err
 	}
-	if strings.HasSuffix(remaining, "S") {
-		remaining = remaining[:len(remaining)-1]
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.compare = compare
+	return nil
+}
+
+// ExportTemplates writes the embedded templates to dir as a starting point for custom templates
+// Existing files are kept unless overwrite is set; returns the paths of the written files
+func ExportTemplates(dir string, overwrite bool) ([]string, error) {
+	names, err := fs.Glob(templateFS, "*.html")
+	if err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, name := range names {
+		target := filepath.Join(dir, name)
+		ok, err := exportFile(templateFS, name, target, overwrite)
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written = append(written, target)
+		}
 	}
+	return written, nil
+}
 
-	var hours, minutes, seconds int
+// Image assets inlined into pages
+const (
+	flagPlaceholderAsset = "images/flag-placeholder.svg"
+	trophyAssetFormat    = "images/trophy-%s.svg"
+)
 
-	// Parse hours
-	if hIdx := strings.Index(remaining, "H"); hIdx > 0 {
-		hours, _ = strconv.Atoi(remaining[:hIdx])
-		remaining = remaining[hIdx+1:]
+// SetAssets selects the theme, locale and images used by the generated pages
+func (g *Generator) SetAssets(assets *Assets, theme, locale string) error {
+	themeCSS, err := assets.Theme(theme)
+	if err != nil {
+		return err
 	}
-
-	// Parse minutes
-	if mIdx := strings.Index(remaining, "M"); mIdx > 0 {
-		minutes, _ = strconv.Atoi(remaining[:mIdx])
-		remaining = remaining[mIdx+1:]
+	texts, err := assets.Locale(locale)
+	if err != nil {
+		return err
 	}
 
-	// Parse seconds (may include decimals)
-	secondsFloat, _ := strconv.ParseFloat(remaining, 64)
-	seconds = int(secondsFloat)
-	millis := int((secondsFloat - float64(seconds)) * 100)
+	images := make(map[string]string)
+	for _, name := range []string{
+		flagPlaceholderAsset,
+		fmt.Sprintf(trophyAssetFormat, "1st"),
+		fmt.Sprintf(trophyAssetFormat, "2nd"),
+		fmt.Sprintf(trophyAssetFormat, "3rd"),
+	} {
+		uri, err := assets.DataURI(name)
+		if err != nil {
+			return err
+		}
+		images[name] = uri
+	}
 
-	if hours > 0 {
-		if millis > 0 {
-			return fmt.Sprintf("%d:%02d:%02d.%02d", hours, minutes, seconds, millis)
+	flagFiles, err := assets.Flags()
+	if err != nil {
+		return err
+	}
+	flags := make(map[string]string, len(flagFiles))
+	for code, data := range flagFiles {
+		symbol, err := flagSymbol(code, data)
+		if err != nil {
+			return err
 		}
-		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+		flags[code] = symbol
 	}
-	if millis > 0 {
-		return fmt.Sprintf("%d:%02d.%02d", minutes, seconds, millis)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.themeCSS = themeCSS
+	g.texts = texts
+	g.images = images
+	g.flags = flags
+	return nil
+}
+
+// text returns a UI string of the selected locale, or the key itself if missing
+func (g *Generator) text(key string) string {
+	if s, ok := g.texts[key]; ok {
+		return s
 	}
-	return fmt.Sprintf("%d:%02d", minutes, seconds)
+	return key
+}
+
+// trophy returns the fallback trophy image of a podium place as a data URI, empty for other places
+func (g *Generator) trophy(place int) string {
+	suffixes := map[int]string{1: "1st", 2: "2nd", 3: "3rd"}
+	suffix, ok := suffixes[place]
+	if !ok {
+		return ""
+	}
+	return g.images[fmt.Sprintf(trophyAssetFormat, suffix)]
+}
+
+// formatDate formats a date with the locale's date_layout, e.g. "March 3, 2025"
+func (g *Generator) formatDate(t time.Time) string {
+	return t.Format(g.text("date_layout"))
+}
+
+// formatTimeISO formats ISO 8601 duration string (e.g., "PT16M25S") to readable format
+func (g *Generator) formatTimeISO(isoTime string) string {
+	return timefmt.FormatSeconds(timefmt.ParseISO(isoTime), g.timeFormat)
+}
+
+// formatSeconds formats seconds (e.g., PrimaryT) to readable format
+func (g *Generator) formatSeconds(seconds float64) string {
+	return timefmt.FormatSeconds(seconds, g.timeFormat)
+}
+
+// formatGap formats a time gap (e.g., "+1.50")
+func (g *Generator) formatGap(seconds float64) string {
+	return timefmt.FormatDelta(seconds, g.timeFormat)
+}
+
+// SetCollation sets the text order of the sortBy template function, the root order by default
+func (g *Generator) SetCollation(c collation.Collation) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.collation = c
+}
+
+// SetTimeFormat sets how fractional seconds are displayed
+func (g *Generator) SetTimeFormat(opts timefmt.Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.timeFormat = opts
+	return nil
 }
 
 // Generate generates static HTML page
 func (g *Generator) Generate(outputPath string, data *LeaderboardData) error {
-	// Ensure output directory exists
-	dir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 
 	// Set CountryCodeMap for template access
 	data.CountryCodeMap = g.countryCodeMap
+	if data.ShowGaps {
+		data.Gaps = ComputeGaps(data.Leaderboard.Runs)
+	}
+	if len(data.Events) > 0 {
+		data.EventTags = TagEvents(data.Leaderboard.Runs, data.Events)
+	}
 
-	// Render template to buffer first
-	var buf bytes.Buffer
-	if err := g.templates.ExecuteTemplate(&buf, "leaderboard.html", data); err != nil {
-		return fmt.Errorf("failed to render template: %w", err)
+	return g.writePage(outputPath, g.templates, "leaderboard.html", data)
+}
+
+// GenerateHub generates the hub page linking all leaderboards of a batch
+func (g *Generator) GenerateHub(outputPath string, data *HubData) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.writePage(outputPath, g.hub, "hub.html", data)
+}
+
+// GenerateCompare generates the page comparing two subcategories of a leaderboard
+func (g *Generator) GenerateCompare(outputPath string, data *CompareData) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.writePage(outputPath, g.compare, "compare.html", data)
+}
+
+// GenerateRetired generates the page replacing a leaderboard whose category or subcategory was removed upstream
+func (g *Generator) GenerateRetired(outputPath string, data *RetiredData) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.writePage(outputPath, g.retired, "retired.html", data)
+}
+
+// GenerateHighlights generates the highlight reel of a leaderboard
+func (g *Generator) GenerateHighlights(outputPath string, data *HighlightsData) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.writePage(outputPath, g.highlights, "highlights.html", data)
+}
+
+// writePage renders a template to the output file
+// The page replaces the previous one only once complete, so a failed rendering keeps the previous page
+// Callers hold g.mu for reading
+func (g *Generator) writePage(outputPath string, tmpl *template.Template, name string, data interface{}) error {
+	file, err := g.storage.Create(outputPath)
+	if err != nil {
+		return err
 	}
+	if err := g.render(file, tmpl, name, data); err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Commit()
+}
+
+// SetStorage sets where pages are written, the local file system by default
+func (g *Generator) SetStorage(st storage.Storage) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.storage = st
+}
 
-	// Create output file
-	file, err := os.Create(outputPath)
+// render executes a template with a fresh flag collector, streaming the minified page to w
+// The flag sprite of the used flags is inserted before the closing body tag
+func (g *Generator) render(w io.Writer, tmpl *template.Template, name string, data interface{}) error {
+	flags := g.newFlagSet()
+	page, err := tmpl.Clone()
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
 	}
-	defer file.Close()
+	page.Funcs(template.FuncMap{"flag": flags.tag})
+	page.Funcs(g.newRunKeys(data).funcs())
 
-	// Minify HTML and write directly to file
+	bw := bufio.NewWriter(w)
+	var out io.Writer = bw
+	var mw *minifyWriter
 	if g.m != nil {
-		if err := g.m.Minify("text/html", file, &buf); err != nil {
+		mw = newMinifyWriter(g.m, bw)
+		out = mw
+	}
+	sw := &spriteWriter{w: out, flags: flags}
+
+	if err := page.ExecuteTemplate(sw, name, data); err != nil {
+		return fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	if err := sw.Close(); err != nil {
+		return fmt.Errorf("failed to minify HTML: %w", err)
+	}
+	if mw != nil {
+		if err := mw.Close(); err != nil {
 			return fmt.Errorf("failed to minify HTML: %w", err)
 		}
-	} else {
-		// No minify, write directly
-		if _, err := file.Write(buf.Bytes()); err != nil {
-			return fmt.Errorf("failed to write output file: %w", err)
-		}
 	}
-
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
 	return nil
 }
 
 // FormatTime formats time display
 func FormatTime(primaryT float64) string {
-	hours := int(primaryT / 3600)
-	remaining := primaryT - float64(hours*3600)
-	minutes := int(remaining / 60)
-	seconds := int(remaining - float64(minutes*60))
-	millis := int((primaryT - float64(int(primaryT))) * 100)
+	return timefmt.FormatSeconds(primaryT, timefmt.Options{ShowZeroFrac: true})
+}
 
-	if hours > 0 {
-		return fmt.Sprintf("%d:%02d:%02d.%02d", hours, minutes, seconds, millis)
+// ComputeGaps computes each run's time gap to the run one place above and to the world record
+// Runs are expected in leaderboard order; tied runs have a zero gap to each other
+func ComputeGaps(runs []models.RunEntry) map[string]RunGap {
+	gaps := make(map[string]RunGap, len(runs))
+	if len(runs) == 0 {
+		return gaps
 	}
-	return fmt.Sprintf("%d:%02d.%02d", minutes, seconds, millis)
+
+	wr := timefmt.Millis(runs[0].Run.Times.PrimaryT)
+	for i, run := range runs {
+		t := timefmt.Millis(run.Run.Times.PrimaryT)
+		gap := RunGap{WR: float64(t-wr) / 1000}
+		if i > 0 {
+			gap.Prev = float64(t-timefmt.Millis(runs[i-1].Run.Times.PrimaryT)) / 1000
+			gap.HasPrev = true
+		}
+		gaps[run.Run.ID] = gap
+	}
+	return gaps
 }
 
 // GetPlayerNames gets player name list
@@ -306,8 +670,9 @@ func GetNameStyleAttr(nameStyle *models.NameStyle) string {
 
 // StyledPlayerName represents styled player name
 type StyledPlayerName struct {
-	Name  string
-	Style string
+	Name        string
+	Style       string
+	Unavailable bool // No player data (failed fetch, deleted account), Name is a placeholder
 }
 
 // GetStyledPlayerName gets styled player name structure
@@ -324,6 +689,19 @@ func GetStyledPlayerName(playerData models.PlayerData) StyledPlayerName {
 	return StyledPlayerName{Name: name, Style: style}
 }
 
+// styledName is GetStyledPlayerName with a localized placeholder for players without data
+func (g *Generator) styledName(playerData models.PlayerData) StyledPlayerName {
+	if !PlayerAvailable(playerData) {
+		return StyledPlayerName{Name: g.text("player_unavailable"), Unavailable: true}
+	}
+	return GetStyledPlayerName(playerData)
+}
+
+// PlayerAvailable reports whether a user's data was loaded, the zero PlayerData of a missing user is not
+func PlayerAvailable(playerData models.PlayerData) bool {
+	return playerData.Names.International != "" || playerData.Name != ""
+}
+
 // firstN returns the first n elements of a slice
 func firstN(v interface{}, n int) interface{} {
 	switch val := v.(type) {
@@ -361,7 +739,11 @@ func CountryFlagURLWithMap(code string, countryCodeMap map[string]string) string
 	if len(code) < 2 {
 		return ""
 	}
+	return fmt.Sprintf("https://www.speedrun.com/images/flags/%s.png", mapCountryCode(code, countryCodeMap))
+}
 
+// mapCountryCode lowercases a country code and applies the replacement rules
+func mapCountryCode(code string, countryCodeMap map[string]string) string {
 	// Convert to lowercase for lookup and URL
 	codeLower := strings.ToLower(code)
 
@@ -372,5 +754,5 @@ func CountryFlagURLWithMap(code string, countryCodeMap map[string]string) string
 		}
 	}
 
-	return fmt.Sprintf("https://www.speedrun.com/images/flags/%s.png", codeLower)
+	return codeLower
 }
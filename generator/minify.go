@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/soar/sr_exhibit/models"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+)
+
+const (
+	// DefaultJSVersion is the ECMAScript version inline scripts are minified for
+	DefaultJSVersion = 2022
+	// minJSVersion is the oldest ECMAScript version after ES5 (5) the minifier knows
+	minJSVersion = 2015
+)
+
+// newMinifier creates the page minifier
+// Inline CSS or JS left without a minifier is copied as is
+func newMinifier(config models.MinifyConfig) (*minify.M, error) {
+	version := config.JSVersion
+	switch {
+	case version == 0:
+		version = DefaultJSVersion
+	case version != 5 && version < minJSVersion:
+		return nil, fmt.Errorf("minify.jsVersion must be 5 (ES5) or a year from %d, got %d", minJSVersion, version)
+	}
+
+	m := minify.New()
+	m.Add("text/html", &html.Minifier{
+		KeepComments:        config.KeepComments,
+		KeepDefaultAttrVals: true,
+		KeepDocumentTags:    true,
+		KeepWhitespace:      false,
+	})
+	if !config.SkipCSS {
+		m.Add("text/css", &css.Minifier{})
+	}
+	if !config.SkipJS {
+		m.Add("text/javascript", &js.Minifier{
+			KeepVarNames: false,
+			Version:      version,
+		})
+	}
+	return m, nil
+}
+
+// SetMinify sets the minifier options, e.g. an older ECMAScript version for inline scripts
+func (g *Generator) SetMinify(config models.MinifyConfig) error {
+	m, err := newMinifier(config)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.m = m
+	return nil
+}
@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/soar/sr_exhibit/collation"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// Rank numbering modes (display.ranking)
+const (
+	RankingStandard = "standard" // Equal times share a place, the next place skips: 1, 1, 3 (default, as on speedrun.com)
+	RankingDense    = "dense"    // Equal times share a place, the next place follows: 1, 1, 2
+)
+
+// Tiebreaks of runs sharing a place (display.tiebreak), the default keeps the order of speedrun.com
+const (
+	TiebreakName = "name" // Player names in the order of the collation
+	TiebreakDate = "date" // Earliest run first
+)
+
+// Ranking describes how places are numbered on pages and in exports
+type Ranking struct {
+	Mode      string              // RankingStandard or RankingDense
+	Offset    int                 // Added to every place, e.g. 100 for a page continuing at 101
+	Hide      bool                // Places are not shown at all, for unranked showcase lists
+	Tiebreak  string              // Order of runs sharing a place: "", TiebreakName or TiebreakDate
+	Collation collation.Collation // Name order of TiebreakName
+}
+
+// NewRanking returns the ranking selected by the display config
+func NewRanking(display models.DisplayConfig) (Ranking, error) {
+	r := Ranking{Mode: display.Ranking, Offset: display.RankOffset, Hide: display.HideRanks, Tiebreak: display.Tiebreak}
+	switch r.Tiebreak {
+	case "", TiebreakName, TiebreakDate:
+	default:
+		return r, fmt.Errorf("unknown display.tiebreak %q (use %s or %s)", r.Tiebreak, TiebreakName, TiebreakDate)
+	}
+	lang := display.Collation
+	if lang == "" && isLanguage(display.Locale) {
+		lang = display.Locale
+	}
+	c, err := collation.New(lang)
+	if err != nil {
+		return r, fmt.Errorf("display.collation: %w", err)
+	}
+	r.Collation = c
+	switch r.Mode {
+	case "":
+		r.Mode = RankingStandard
+	case RankingStandard, RankingDense:
+	default:
+		return r, fmt.Errorf("unknown display.ranking %q (use %s or %s)", r.Mode, RankingStandard, RankingDense)
+	}
+	if r.Offset < 0 {
+		return r, fmt.Errorf("display.rankOffset must not be negative")
+	}
+	return r, nil
+}
+
+// Apply returns a copy of the runs, which are ordered by place, with ties ordered and places renumbered
+// Hidden ranks leave every place at 0; runs without a place (0) keep it
+// players resolves the names of users for TiebreakName
+func (r Ranking) Apply(runs []models.RunEntry, players map[string]models.PlayerData) []models.RunEntry {
+	ranked := make([]models.RunEntry, len(runs))
+	copy(ranked, runs)
+	r.breakTies(ranked, players)
+	dense, prev := 0, 0
+	for i := range ranked {
+		place := ranked[i].Place
+		if place == 0 {
+			continue
+		}
+		switch {
+		case r.Hide:
+			ranked[i].Place = 0
+		case r.Mode == RankingDense:
+			if place != prev {
+				dense++
+				prev = place
+			}
+			ranked[i].Place = dense + r.Offset
+		default:
+			ranked[i].Place = place + r.Offset
+		}
+	}
+	return ranked
+}
+
+// breakTies orders the runs of each place shared by several runs
+func (r Ranking) breakTies(runs []models.RunEntry, players map[string]models.PlayerData) {
+	if r.Tiebreak == "" {
+		return
+	}
+	var less func(a, b models.RunEntry) bool
+	switch r.Tiebreak {
+	case TiebreakDate:
+		// ISO dates order as strings, runs without a date last
+		less = func(a, b models.RunEntry) bool {
+			return a.Run.Date != "" && (b.Run.Date == "" || a.Run.Date < b.Run.Date)
+		}
+	case TiebreakName:
+		compare := r.Collation.Compare()
+		less = func(a, b models.RunEntry) bool {
+			return compare(runNames(a.Run, players), runNames(b.Run, players)) < 0
+		}
+	}
+	for start := 0; start < len(runs); {
+		end := start + 1
+		for end < len(runs) && runs[start].Place != 0 && runs[end].Place == runs[start].Place {
+			end++
+		}
+		if end-start > 1 {
+			tied := runs[start:end]
+			sort.SliceStable(tied, func(i, j int) bool { return less(tied[i], tied[j]) })
+		}
+		start = end
+	}
+}
+
+// runNames returns the player names of a run as shown on the page, e.g. "Alice, Bob"
+func runNames(run models.RunData, players map[string]models.PlayerData) string {
+	names := make([]string, 0, len(run.Players))
+	for _, p := range run.Players {
+		if pd, ok := players[p.ID]; ok && p.Rel == "user" && pd.Names.International != "" {
+			names = append(names, pd.Names.International)
+		} else if p.Name != "" {
+			names = append(names, p.Name)
+		} else {
+			names = append(names, p.ID)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// isLanguage reports whether a display.locale is a language tag rather than a locale file name
+func isLanguage(locale string) bool {
+	_, err := collation.New(locale)
+	return locale != "" && err == nil
+}
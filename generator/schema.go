@@ -0,0 +1,19 @@
+package generator
+
+import (
+	"reflect"
+
+	"github.com/soar/sr_exhibit/schema"
+)
+
+// TemplateData lists the page templates with the type of the data they are executed with
+func TemplateData() []schema.Root {
+	return []schema.Root{
+		{Name: "leaderboard.html", Type: reflect.TypeOf(LeaderboardData{})},
+		{Name: "hub.html", Type: reflect.TypeOf(HubData{})},
+		{Name: "compare.html", Type: reflect.TypeOf(CompareData{})},
+		{Name: "retired.html", Type: reflect.TypeOf(RetiredData{})},
+		{Name: "highlights.html", Type: reflect.TypeOf(HighlightsData{})},
+		{Name: "podium.html", Type: reflect.TypeOf(PodiumData{})},
+	}
+}
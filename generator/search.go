@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"encoding/json"
+
+	"github.com/soar/sr_exhibit/collation"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// SearchIndex is the client-side search index of the hub page (hub.search): the boards, and every
+// player with their place on each board, so visitors find where a player ranks across the pages
+type SearchIndex struct {
+	Boards  []SearchBoard  `json:"boards"`
+	Players []SearchPlayer `json:"players"`
+	players map[string]int // Index in Players keyed by player key
+}
+
+// SearchBoard is a board of the search index
+type SearchBoard struct {
+	Title string `json:"title"` // e.g. "Super Mario Sunshine - Any% (GCN)"
+	Key   string `json:"key"`   // Folded title, see collation.Fold
+	Link  string `json:"link"`  // Page path relative to the hub page
+}
+
+// SearchPlayer is a player of the search index
+type SearchPlayer struct {
+	Name   string        `json:"name"`
+	Key    string        `json:"key"` // Folded name, see collation.Fold
+	Places []SearchPlace `json:"places"`
+}
+
+// SearchPlace is the best place of a player on a board
+type SearchPlace struct {
+	Board int `json:"board"` // Index in Boards
+	Place int `json:"place"` // 0 when ranks are hidden
+}
+
+// NewSearchIndex returns an empty search index
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{players: make(map[string]int)}
+}
+
+// Add indexes a board of the hub and the players of its runs, which are ordered by place
+// Users without player data can't be searched by name and are left out
+func (x *SearchIndex) Add(board HubBoard, runs []models.RunEntry) {
+	title := board.Title()
+	index := len(x.Boards)
+	x.Boards = append(x.Boards, SearchBoard{Title: title, Key: collation.Fold(title), Link: board.Link})
+
+	seen := make(map[string]bool)
+	for _, e := range runs {
+		for _, p := range e.Run.Players {
+			key, name := p.Key(), p.Name
+			if p.Rel == "user" {
+				name = board.Players[p.ID].Names.International
+			}
+			if name == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			i, ok := x.players[key]
+			if !ok {
+				i = len(x.Players)
+				x.players[key] = i
+				x.Players = append(x.Players, SearchPlayer{Name: name, Key: collation.Fold(name)})
+			}
+			x.Players[i].Places = append(x.Players[i].Places, SearchPlace{Board: index, Place: e.Place})
+		}
+	}
+}
+
+// JSON returns the index as JSON, with <, > and & escaped so it can be embedded in a script element
+func (x *SearchIndex) JSON() (string, error) {
+	data, err := json.Marshal(x)
+	return string(data), err
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sparklineWidth and sparklineHeight are the size of a rank sparkline in pixels
+	sparklineWidth  = 64
+	sparklineHeight = 18
+	// sparklinePad keeps the line and its end dot inside the image
+	sparklinePad = 2.5
+)
+
+// Trajectory is a player's standings on the last snapshots, oldest first
+type Trajectory []TrajectoryPoint
+
+// TrajectoryPoint is a player's standing on one snapshot
+type TrajectoryPoint struct {
+	TakenAt time.Time
+	Place   int     // 0 if the player was not on the board
+	Time    float64 // Seconds (or score), 0 if the player was not on the board
+}
+
+// sparkline renders the places of a trajectory as an inline SVG, better places drawn higher
+// Snapshots without the player break the line; fewer than two places render nothing
+// label starts the tooltip, e.g. "Rank history: 5 → 4 → – → 3"
+func sparkline(points Trajectory, label string) string {
+	minPlace, maxPlace, present := 0, 0, 0
+	for _, p := range points {
+		if p.Place == 0 {
+			continue
+		}
+		if present == 0 || p.Place < minPlace {
+			minPlace = p.Place
+		}
+		if p.Place > maxPlace {
+			maxPlace = p.Place
+		}
+		present++
+	}
+	if present < 2 {
+		return ""
+	}
+
+	x := func(i int) float64 {
+		return sparklinePad + float64(i)*(sparklineWidth-2*sparklinePad)/float64(len(points)-1)
+	}
+	y := func(place int) float64 {
+		if minPlace == maxPlace {
+			return sparklineHeight / 2
+		}
+		return sparklinePad + float64(place-minPlace)*(sparklineHeight-2*sparklinePad)/float64(maxPlace-minPlace)
+	}
+	coord := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+
+	places := make([]string, len(points))
+	var b strings.Builder
+	var segment []string // "x,y" of the consecutive snapshots with the player
+	flush := func() {
+		if len(segment) > 1 {
+			fmt.Fprintf(&b, `<polyline points="%s"/>`, strings.Join(segment, " "))
+		}
+		segment = segment[:0]
+	}
+	for i, p := range points {
+		if p.Place == 0 {
+			places[i] = "–"
+			flush()
+			continue
+		}
+		places[i] = strconv.Itoa(p.Place)
+		segment = append(segment, coord(x(i))+","+coord(y(p.Place)))
+		if (i == 0 || points[i-1].Place == 0) && i < len(points)-1 && points[i+1].Place == 0 {
+			// A snapshot between two absences has no line, mark it with a dot (the last one gets the end dot)
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="1"/>`, coord(x(i)), coord(y(p.Place)))
+		}
+	}
+	flush()
+	if last := points[len(points)-1]; last.Place != 0 {
+		fmt.Fprintf(&b, `<circle class="end" cx="%s" cy="%s" r="2"/>`, coord(x(len(points)-1)), coord(y(last.Place)))
+	}
+
+	title := html.EscapeString(label + ": " + strings.Join(places, " → "))
+	return fmt.Sprintf(`<svg class="rank-sparkline" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s"><title>%s</title>%s</svg>`,
+		sparklineWidth, sparklineHeight, sparklineWidth, sparklineHeight, title, title, b.String())
+}
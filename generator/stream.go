@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/tdewolff/minify/v2"
+)
+
+// minifyChunkSize is the size of rendered HTML above which a page is minified in chunks
+const minifyChunkSize = 1 << 20
+
+var (
+	bodyEnd = []byte("</body>")
+	rowEnd  = []byte("</tr>")
+	// rawElements are skipped when looking for a row end: their content isn't HTML, e.g. "</tr>" in a script string
+	rawElements = []struct{ open, close []byte }{
+		{[]byte("<script"), []byte("</script")},
+		{[]byte("<style"), []byte("</style")},
+		{[]byte("<!--"), []byte("-->")},
+	}
+)
+
+// spriteWriter passes a rendered page through, holding back its end from the last "</body>",
+// so the flag sprite, only known once the page is rendered, can be inserted before it
+type spriteWriter struct {
+	w       io.Writer
+	flags   *flagSet
+	pending []byte
+}
+
+// Write writes p except what may be the start of the last "</body>"
+func (s *spriteWriter) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	keep := bytes.LastIndex(s.pending, bodyEnd)
+	if keep < 0 {
+		// A partial "</body>" may end the data
+		keep = max(len(s.pending)-len(bodyEnd)+1, 0)
+	}
+	if keep > 0 {
+		if _, err := s.w.Write(s.pending[:keep]); err != nil {
+			return 0, err
+		}
+		s.pending = append(s.pending[:0], s.pending[keep:]...)
+	}
+	return len(p), nil
+}
+
+// Close writes the held back end of the page with the sprite inserted before "</body>", or appended
+func (s *spriteWriter) Close() error {
+	sprite := []byte(s.flags.sprite())
+	var err error
+	if bytes.HasPrefix(s.pending, bodyEnd) {
+		_, err = s.w.Write(append(sprite, s.pending...))
+	} else {
+		_, err = s.w.Write(append(s.pending, sprite...))
+	}
+	s.pending = nil
+	return err
+}
+
+// minifyWriter minifies the HTML written to it
+// Pages up to minifyChunkSize are minified whole; larger ones (e.g. boards with thousands of runs)
+// are minified in chunks split after table rows, so neither the minifier nor the output holds the whole page
+// Chunks are never split inside a script, style or comment, custom templates may have "</tr>" in a script
+type minifyWriter struct {
+	m    *minify.M
+	w    io.Writer
+	buf  []byte
+	next int // Buffer size at which a chunk is looked for
+}
+
+// newMinifyWriter creates a minifying writer to w
+func newMinifyWriter(m *minify.M, w io.Writer) *minifyWriter {
+	return &minifyWriter{m: m, w: w, next: minifyChunkSize}
+}
+
+// Write buffers p, minifying the buffered rows once a chunk is complete
+func (z *minifyWriter) Write(p []byte) (int, error) {
+	z.buf = append(z.buf, p...)
+	if len(z.buf) < z.next {
+		return len(p), nil
+	}
+	end := lastRowEnd(z.buf)
+	if end < 0 {
+		// No row to split after, look again after another chunk
+		z.next = len(z.buf) + minifyChunkSize
+		return len(p), nil
+	}
+	if err := z.m.Minify("text/html", z.w, bytes.NewReader(z.buf[:end])); err != nil {
+		return 0, err
+	}
+	z.buf = append(z.buf[:0], z.buf[end:]...)
+	z.next = minifyChunkSize
+	return len(p), nil
+}
+
+// Close minifies the rest of the page
+func (z *minifyWriter) Close() error {
+	err := z.m.Minify("text/html", z.w, bytes.NewReader(z.buf))
+	z.buf = nil
+	return err
+}
+
+// lastRowEnd returns the position after the last "</tr>" of HTML outside scripts, styles and comments, -1 if none
+// The HTML must not start inside one of them, which holds for the chunks of minifyWriter
+func lastRowEnd(html []byte) int {
+	last := -1
+	for i := 0; ; {
+		j := bytes.IndexByte(html[i:], '<')
+		if j < 0 {
+			return last
+		}
+		i += j
+		rest := html[i:]
+		if hasPrefixFold(rest, rowEnd) {
+			last = i + len(rowEnd)
+			i = last
+			continue
+		}
+		skipped := false
+		for _, raw := range rawElements {
+			if !hasPrefixFold(rest, raw.open) {
+				continue
+			}
+			end := indexFold(rest[len(raw.open):], raw.close)
+			if end < 0 {
+				return last // Unclosed: the rest of the HTML belongs to the element
+			}
+			i += len(raw.open) + end + len(raw.close)
+			skipped = true
+			break
+		}
+		if !skipped {
+			i++
+		}
+	}
+}
+
+// hasPrefixFold reports whether b begins with the ASCII prefix, ignoring case
+func hasPrefixFold(b, prefix []byte) bool {
+	return len(b) >= len(prefix) && bytes.EqualFold(b[:len(prefix)], prefix)
+}
+
+// indexFold returns the index of the first ASCII sep in b ignoring case, -1 if none
+func indexFold(b, sep []byte) int {
+	for i := 0; i+len(sep) <= len(b); i++ {
+		if hasPrefixFold(b[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
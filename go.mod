@@ -2,9 +2,10 @@ module github.com/soar/sr_exhibit
 
 go 1.25.7
 
-require gopkg.in/yaml.v3 v3.0.1
-
 require (
-	github.com/tdewolff/minify/v2 v2.24.8 // indirect
-	github.com/tdewolff/parse/v2 v2.8.5 // indirect
+	github.com/tdewolff/minify/v2 v2.24.8
+	golang.org/x/text v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/tdewolff/parse/v2 v2.8.5 // indirect
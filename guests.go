@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/collation"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// guestMatch is a guest run whose name matches a user, likely submitted before the player had an account
+type guestMatch struct {
+	Board     string // Snapshot title of the guest run's board
+	Guest     string
+	RunID     string
+	Place     int
+	Time      float64
+	Date      string
+	User      string // Matching user name
+	UserID    string
+	SameBoard bool // The user has a run on the same board, otherwise on another archived board
+	Exact     bool // Same name ignoring case, otherwise only the letters and digits match
+}
+
+// archivedUser is a user seen on the latest snapshot of an archived board
+type archivedUser struct {
+	name   string
+	boards map[string]bool // Boards the user has a run on
+}
+
+// findGuestMatches compares the guest names of every archived board's latest standings with the users
+// of all boards, matching names that are equal once case and punctuation are ignored
+func findGuestMatches(snapshots *cache.SnapshotStore) ([]guestMatch, error) {
+	boards, err := snapshots.Boards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	latest := make(map[string]*cache.Snapshot, len(boards))
+	users := make(map[string]*archivedUser) // User ID -> user
+	byName := make(map[string][]string)     // Normalized name -> user IDs
+	for _, board := range boards {
+		history, err := snapshots.History(board)
+		if err != nil {
+			return nil, err
+		}
+		if len(history) == 0 {
+			continue
+		}
+		snap := history[len(history)-1]
+		latest[board] = snap
+		for _, e := range snap.Entries {
+			for _, player := range splitPlayers(e.PlayerKey, e.PlayerName) {
+				if strings.HasPrefix(player.key, "guest:") {
+					continue
+				}
+				u, ok := users[player.key]
+				if !ok {
+					u = &archivedUser{name: player.name, boards: make(map[string]bool)}
+					users[player.key] = u
+					norm := collation.Fold(player.name)
+					byName[norm] = append(byName[norm], player.key)
+				}
+				u.boards[board] = true
+			}
+		}
+	}
+
+	var matches []guestMatch
+	for _, board := range boards {
+		snap := latest[board]
+		if snap == nil {
+			continue
+		}
+		for _, e := range snap.Entries {
+			for _, player := range splitPlayers(e.PlayerKey, e.PlayerName) {
+				if !strings.HasPrefix(player.key, "guest:") {
+					continue
+				}
+				for _, id := range byName[collation.Fold(player.name)] {
+					u := users[id]
+					matches = append(matches, guestMatch{
+						Board:     snap.Title,
+						Guest:     player.name,
+						RunID:     e.RunID,
+						Place:     e.Place,
+						Time:      e.PrimaryT,
+						Date:      e.Date,
+						User:      u.name,
+						UserID:    id,
+						SameBoard: u.boards[board],
+						Exact:     strings.EqualFold(player.name, u.name),
+					})
+				}
+			}
+		}
+	}
+
+	// Most likely matches first: same board, exact name
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.SameBoard != b.SameBoard {
+			return a.SameBoard
+		}
+		if a.Exact != b.Exact {
+			return a.Exact
+		}
+		if a.Board != b.Board {
+			return a.Board < b.Board
+		}
+		return a.Place < b.Place
+	})
+	return matches, nil
+}
+
+// snapshotPlayer is one player of a snapshot entry
+type snapshotPlayer struct {
+	key  string // See models.Player.Key
+	name string
+}
+
+// splitPlayers splits the players of a snapshot entry, multiplayer runs join keys with "+" and names with ", "
+func splitPlayers(playerKey, playerName string) []snapshotPlayer {
+	keys := strings.Split(playerKey, "+")
+	names := strings.Split(playerName, ", ")
+	if len(names) != len(keys) {
+		// A name containing ", " can't be told apart, keep the whole name for a single player only
+		if len(keys) != 1 {
+			return nil
+		}
+		names = []string{playerName}
+	}
+	players := make([]snapshotPlayer, len(keys))
+	for i := range keys {
+		players[i] = snapshotPlayer{key: keys[i], name: names[i]}
+	}
+	return players
+}
+
+// renderGuestReport renders the guest matches as a Markdown report for moderators
+func renderGuestReport(matches []guestMatch, opts timefmt.Options) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Guest runs matching users\n\n")
+	if len(matches) == 0 {
+		b.WriteString("No guest run matches a user.\n")
+		return b.Bytes()
+	}
+	b.WriteString("Guest runs whose name matches a user, likely submitted before the player had an account.\n")
+	b.WriteString("Moderators can ask the players to link them.\n\n")
+	b.WriteString("| Board | Place | Guest | Time | Date | Run ID | User | Match |\n")
+	b.WriteString("|-------|-------|-------|------|------|--------|------|-------|\n")
+	for _, m := range matches {
+		match := "other board"
+		if m.SameBoard {
+			match = "same board"
+		}
+		if !m.Exact {
+			match += ", similar name"
+		}
+		fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %s | %s (%s) | %s |\n",
+			markdownCell(m.Board), m.Place, markdownCell(m.Guest), timefmt.FormatSeconds(m.Time, opts),
+			m.Date, m.RunID, markdownCell(m.User), m.UserID, match)
+	}
+	return b.Bytes()
+}
+
+// markdownCell escapes the pipes of a Markdown table cell
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// runGuestReport writes the guest-to-user report of the archived boards to outputPath, or stdout if empty
+func runGuestReport(snapshots *cache.SnapshotStore, outputPath string, opts timefmt.Options) error {
+	matches, err := findGuestMatches(snapshots)
+	if err != nil {
+		return err
+	}
+	content := renderGuestReport(matches, opts)
+
+	if outputPath == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(outputPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write guest report: %w", err)
+	}
+	fmt.Printf("✓ Guest report written to %s (%d matches)\n", outputPath, len(matches))
+	return nil
+}
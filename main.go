@@ -3,23 +3,32 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/soar/sr_exhibit/api"
 	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/export"
 	"github.com/soar/sr_exhibit/generator"
 	"github.com/soar/sr_exhibit/models"
-	"gopkg.in/yaml.v3"
+	"github.com/soar/sr_exhibit/progress"
+	"github.com/soar/sr_exhibit/schema"
+	"github.com/soar/sr_exhibit/storage"
 )
 
 const (
 	version            = "1.0.0"
 	configTemplateFile = "config.yaml.template"
+	// cacheLockTimeout is how long to wait for another run using the same cache directory
+	cacheLockTimeout = 2 * time.Minute
 )
 
 func main() {
@@ -39,6 +48,21 @@ func main() {
 		showCacheList   bool   // Show cache list
 		clearCache      bool   // Clear cache
 		generateConfig  bool   // Generate config file
+		digestMode      bool          // Generate digest
+		digestPeriod    time.Duration // Digest period
+		digestFormat    string        // Digest output format
+		digestOutput    string        // Digest output path
+		guestReport     bool          // Report guest runs matching users
+		guestOutput     string        // Guest report output path
+		ignoreQuiet     bool          // Generate even during the quiet hours
+		printFormat     string        // Print the effective config in this format
+		pruneOnly       bool          // Prune the snapshot archive, then exit
+		exportAssetsDir string        // Export embedded assets to this directory
+		exportTemplates string        // Export embedded templates to this directory
+		compareStr      string        // Compare two subcategory values
+		refreshMetadata bool          // Refetch game, category and variable metadata
+		schemaFormat    string        // Print the template data schema in this format
+		schemaOf        string        // Data documented by --schema: templates or export
 	)
 
 	flag.StringVar(&configFile, "config", "", "Config file path (YAML)")
@@ -52,10 +76,28 @@ func main() {
 	flag.BoolVar(&showVersion, "version", false, "Show version info")
 	flag.BoolVar(&useCache, "use-cache", false, "Force use cached data")
 	flag.BoolVar(&refreshCache, "refresh-cache", false, "Force refresh and update cache")
+	flag.BoolVar(&refreshMetadata, "refresh-metadata", false, "Refetch cached game, category and variable metadata")
 	flag.BoolVar(&showCacheList, "cache-list", false, "List all cached leaderboards")
 	flag.BoolVar(&clearCache, "cache-clear", false, "Clear all leaderboard cache")
+	flag.BoolVar(&pruneOnly, "prune-snapshots", false, "Delete the snapshots outside cache.snapshots retention, then exit")
 	flag.BoolVar(&generateConfig, "generate", false, "Generate config.yaml from template")
+	flag.BoolVar(&digestMode, "digest", false, "Generate a \"record of the week\" digest from snapshots")
+	flag.DurationVar(&digestPeriod, "digest-period", defaultDigestPeriod, "Digest period")
+	flag.StringVar(&digestFormat, "digest-format", "markdown", "Digest format (markdown, html, rss)")
+	flag.StringVar(&digestOutput, "digest-output", "", "Digest output file path (default: stdout)")
+	flag.BoolVar(&guestReport, "guest-report", false, "Report guest runs whose name matches a user, from snapshots")
+	flag.StringVar(&guestOutput, "guest-report-output", "", "Guest report output file path (default: stdout)")
+	flag.StringVar(&printFormat, "print-config", "", "Print the effective config (file, includes, flags and defaults merged) as yaml or json")
+	flag.BoolVar(&ignoreQuiet, "ignore-quiet-hours", false, "Generate even during the configured quiet hours (schedule.quietHours)")
+	flag.StringVar(&exportAssetsDir, "export-assets", "", "Export embedded assets (themes, images, flags, locales) to directory for customization")
+	flag.StringVar(&exportTemplates, "export-template", "", "Export embedded templates (leaderboard.html, hub.html, compare.html) to directory for customization")
+	flag.StringVar(&schemaFormat, "schema", "", "Print the data available to templates: json (JSON Schema) or markdown (field reference)")
+	flag.StringVar(&schemaOf, "schema-of", "templates", "Data documented by --schema: templates (template data) or export (JSON export files)")
+	flag.StringVar(&compareStr, "compare", "", "Compare two subcategory values of the category (format: \"PC,Console\")")
 	flag.Parse()
+	// Time source of the run: quiet hours, digest window, snapshot pruning and the session (snapshots, caches, exports)
+	clk := clock.System
+	started := clk.Now()
 
 	if showVersion {
 		fmt.Printf("sr_exhibit v%s\n", version)
@@ -71,6 +113,30 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Export assets mode
+	if exportAssetsDir != "" {
+		if err := exportAssets(exportAssetsDir); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	// Export templates mode
+	if exportTemplates != "" {
+		if err := exportTemplateFiles(exportTemplates); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	// Schema mode
+	if schemaFormat != "" {
+		if err := printSchema(schemaFormat, schemaOf); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
 	// Load config
 	var config models.Config
 	configFileToUse := configFile
@@ -83,10 +149,11 @@ func main() {
 	// Try to read config file
 	data, err := os.ReadFile(configFileToUse)
 	if err == nil {
-		// Config file exists, parse it
-		if err := yaml.Unmarshal(data, &config); err != nil {
+		// Config file exists, parse it along with its includes
+		config, err = parseConfig(configFileToUse, data)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Failed to parse config file: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitConfig)
 		}
 		// Command line args override config file
 		if gameName != "" {
@@ -108,12 +175,12 @@ func main() {
 		// Config file doesn't exist, use command line args or defaults
 		if !os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "Error: Failed to read config file: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitConfig)
 		}
-		if gameName == "" && !showCacheList && !clearCache {
+		if gameName == "" && !showCacheList && !clearCache && !digestMode && !guestReport {
 			fmt.Fprintf(os.Stderr, "Error: Game name must be specified (use -game flag or config file)\n")
 			fmt.Fprintf(os.Stderr, "Use -h to see help\n")
-			os.Exit(1)
+			os.Exit(exitConfig)
 		}
 		config.Game = gameName
 		config.Category = categoryName
@@ -122,11 +189,65 @@ func main() {
 		config.API.Timeout = timeout
 	}
 
+	config, err = applyExhibition(config)
+	if err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+
 	// Parse timeout duration
 	duration, err := time.ParseDuration(config.API.Timeout)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Invalid timeout format: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitConfig)
+	}
+	if err := config.TimeFormat.Validate(); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if _, err := staleThreshold(config); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if err := validateFallback(config); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if _, err := generator.ParseEvents(config.Events); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if _, err := generator.NewRanking(config.Display); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if config.Display.VisibleRows < 0 {
+		exitWithError(withExitCode(exitConfig, fmt.Errorf("display.visibleRows must not be negative")))
+	}
+	if config.Display.Sparkline < 0 {
+		exitWithError(withExitCode(exitConfig, fmt.Errorf("display.sparkline must not be negative")))
+	}
+	if _, err := heatmapFade(config); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if config.Workers < 0 {
+		exitWithError(withExitCode(exitConfig, fmt.Errorf("workers must not be negative")))
+	}
+	if config.Highlights.Runs < 0 || config.Highlights.Seconds < 0 {
+		exitWithError(withExitCode(exitConfig, fmt.Errorf("highlights.runs and highlights.seconds must not be negative")))
+	}
+	if _, err := storage.New(config.Storage); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if _, err := newNotifier(config, duration); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if err := validatePublish(config); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if err := validateRetention(config.Cache.Snapshots); err != nil {
+		exitWithError(withExitCode(exitConfig, err))
+	}
+	if pruneOnly && !snapshotRetention(config.Cache.Snapshots).Enabled() {
+		exitWithError(withExitCode(exitConfig, fmt.Errorf("--prune-snapshots needs cache.snapshots keepLast or keepWeekly in the config")))
+	}
+	quiet, err := parseQuietHours(config.Schedule)
+	if err != nil {
+		exitWithError(withExitCode(exitConfig, err))
 	}
 
 	// Parse command line specified variables
@@ -142,6 +263,14 @@ func main() {
 		}
 	}
 
+	var compareValues [2]string
+	if compareStr != "" {
+		compareValues, err = parseCompareValues(compareStr)
+		if err != nil {
+			exitWithError(withExitCode(exitConfig, err))
+		}
+	}
+
 	// Determine template path to use
 	finalTemplatePath := templatePath
 	if finalTemplatePath == "" && config.Template != "" {
@@ -149,11 +278,22 @@ func main() {
 	}
 
 	// Initialize leaderboard cache
-	cacheDir := cache.DefaultCacheDir
-	if config.Cache.Dir != "" {
-		cacheDir = config.Cache.Dir
+	// A relative cache directory in the config file is relative to the config file, not the working directory
+	configuredCacheDir := config.Cache.Dir
+	if configuredCacheDir != "" && !filepath.IsAbs(configuredCacheDir) && data != nil {
+		configuredCacheDir = filepath.Join(filepath.Dir(configFileToUse), configuredCacheDir)
 	}
+	cacheDir := cache.ResolveDir(configuredCacheDir)
 	leaderboardCache := cache.NewLeaderboardCache(cacheDir)
+	snapshotStore := cache.NewSnapshotStore(cacheDir)
+
+	// Print config mode: what a run with these arguments would use, nothing is fetched or written
+	if printFormat != "" {
+		if err := printConfig(effectiveConfig(config, cacheDir, subcategoryStr, varFilters), printFormat); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
 
 	// Handle cache related commands
 	if showCacheList {
@@ -173,16 +313,165 @@ func main() {
 		os.Exit(0)
 	}
 
+	if digestMode {
+		if err := runDigest(snapshotStore, digestPeriod, digestFormat, digestOutput, config.TimeFormat, clk); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	if guestReport {
+		if err := runGuestReport(snapshotStore, guestOutput, config.TimeFormat); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	// Scheduled runs stay idle during the quiet hours, e.g. overnight while the venue is closed
+	if quiet != nil && !ignoreQuiet && quiet.contains(clk.Now()) {
+		fmt.Printf("Quiet hours (%s), nothing generated\n", quiet)
+		os.Exit(exitOK)
+	}
+
+	// Runs sharing a cache directory (e.g. overlapping scheduled tasks) must not write it concurrently
+	lock, err := cache.AcquireLock(cacheDir, cacheLockTimeout)
+	if errors.Is(err, cache.ErrLocked) {
+		exitWithError(withExitCode(exitLocked, err))
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if refreshMetadata {
+		if err := cache.NewMetadataCache(cacheDir).Clear(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if pruneOnly {
+		err := pruneSnapshots(snapshotStore, config.Cache.Snapshots, clk)
+		lock.Release()
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	// Long operations report progress on stdout: bars on a terminal, periodic lines otherwise
+	ctx := progress.NewContext(context.Background(), os.Stdout)
+	// Run statistics for the operator's stats file, if configured
+	stats := &usage{StartedAt: started, Version: version}
+	ctx = withUsage(ctx, stats)
+
+	// Compare mode: one page comparing two subcategory values
+	if compareStr != "" {
+		compareOutput := ""
+		if outputDir != "./output" {
+			compareOutput = outputDir
+		}
+		err := runCompare(ctx, config, duration, clk, compareValues, compareOutput, finalTemplatePath, cacheDir, leaderboardCache, snapshotStore, useCache, refreshCache)
+		writeUsage(config.StatsFile, stats, err)
+		lock.Release()
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	// Batch mode: generate every configured leaderboard plus a hub page
+	if len(config.Leaderboards) > 0 && gameName == "" {
+		err := runBatch(ctx, config, duration, clk, templatePath, cacheDir, leaderboardCache, snapshotStore, useCache, refreshCache)
+		if err == nil {
+			err = publishOutput(ctx, config, true)
+		}
+		if perr := pruneSnapshots(snapshotStore, config.Cache.Snapshots, clk); perr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", perr)
+		}
+		writeUsage(config.StatsFile, stats, err)
+		lock.Release()
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("✓ Batch generated successfully!")
+		os.Exit(0)
+	}
+
 	// Execute generation
-	if err := run(context.Background(), config, duration, varFilters, subcategoryStr, finalTemplatePath, leaderboardCache, useCache, refreshCache); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	err = run(ctx, config, duration, clk, varFilters, subcategoryStr, finalTemplatePath, cacheDir, leaderboardCache, snapshotStore, useCache, refreshCache)
+	if err == nil {
+		err = publishOutput(ctx, config, false)
+	}
+	if perr := pruneSnapshots(snapshotStore, config.Cache.Snapshots, clk); perr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", perr)
+	}
+	writeUsage(config.StatsFile, stats, err)
+	lock.Release()
+	if err != nil {
+		exitWithError(err)
 	}
 
 	fmt.Println("✓ Page generated successfully!")
 	fmt.Printf("Output: %s\n", config.Output)
 }
 
+// exportAssets writes the embedded assets to dir, keeping files that already exist
+func exportAssets(dir string) error {
+	written, err := generator.ExportAssets(dir, false)
+	for _, path := range written {
+		fmt.Printf("  ✓ %s\n", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export assets: %w", err)
+	}
+	fmt.Printf("✓ Exported %d asset files to %s (existing files kept)\n", len(written), dir)
+	fmt.Println("Set assetsDir in config.yaml to this directory to use them")
+	return nil
+}
+
+// exportTemplateFiles writes the embedded templates to dir, keeping files that already exist
+func exportTemplateFiles(dir string) error {
+	written, err := generator.ExportTemplates(dir, false)
+	for _, path := range written {
+		fmt.Printf("  ✓ %s\n", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export templates: %w", err)
+	}
+	fmt.Printf("✓ Exported %d template files to %s (existing files kept)\n", len(written), dir)
+	fmt.Println("Use them with --template / template: (leaderboard pages) and hub.template: (hub page)")
+	return nil
+}
+
+// printSchema prints the data model of the page templates or of the JSON export, generated from the Go types
+func printSchema(format, of string) error {
+	title := "sr_exhibit template data"
+	roots := generator.TemplateData()
+	naming := schema.GoNames
+	switch of {
+	case "templates":
+	case "export":
+		title = fmt.Sprintf("sr_exhibit JSON export (schema_version %d)", export.SchemaVersion)
+		roots = export.Documents()
+		naming = schema.JSONNames
+	default:
+		return withExitCode(exitConfig, fmt.Errorf("unknown --schema-of: %s (use templates or export)", of))
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(schema.JSONSchema(title, version, naming, roots), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "markdown", "md":
+		fmt.Print(schema.Markdown(title, version, naming, roots))
+	default:
+		return withExitCode(exitConfig, fmt.Errorf("unknown schema format: %s (use json or markdown)", format))
+	}
+	return nil
+}
+
 func listCaches(lbCache *cache.LeaderboardCache) error {
 	files, err := lbCache.List()
 	if err != nil {
@@ -254,7 +543,7 @@ func generateWithSelection(ngame, ncategory, nsubcategory string) error {
 	}
 
 	// Create client and fetch game info
-	ctx := context.Background()
+	ctx := progress.NewContext(context.Background(), os.Stdout)
 	duration, err := time.ParseDuration("30s")
 	if err != nil {
 		return fmt.Errorf("failed to parse timeout: %w", err)
@@ -455,398 +744,36 @@ func isInteractive() bool {
 }
 
 // run executes the main program logic
-func run(ctx context.Context, config models.Config, timeout time.Duration, varFilters map[string]string, subcategoryValue string, templatePath string, lbCache *cache.LeaderboardCache, useCache, refreshCache bool) error {
-	client := api.NewClient(config.API.BaseURL, timeout)
-
-	// Initialize player cache
-	playerCache, err := cache.NewPlayerCache(cache.DefaultCacheDir, cache.DefaultTTL)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize cache, caching disabled: %v\n", err)
-	} else {
-		client.SetPlayerCache(playerCache)
-		if removed := playerCache.CleanExpired(); removed > 0 {
-			fmt.Printf("Cache: Cleaned %d expired entries\n", removed)
-		}
-		if total, expired := playerCache.Stats(); total > 0 {
-			fmt.Printf("Cache: %d entries (%d expired)\n", total, expired)
-		}
-	}
-
-	fmt.Printf("Searching game: %s\n", config.Game)
-	game, err := client.SearchGameByName(ctx, config.Game)
-	if err != nil {
-		return fmt.Errorf("failed to search game: %w", err)
+func run(ctx context.Context, config models.Config, timeout time.Duration, clk clock.Clock, varFilters map[string]string, subcategoryValue string, templatePath string, cacheDir string, lbCache *cache.LeaderboardCache, snapshots *cache.SnapshotStore, useCache, refreshCache bool) error {
+	s := newSession(config, timeout, clk, cacheDir, lbCache, snapshots, useCache, refreshCache)
+	defer s.recordUsage(ctx, "single")
+
+	// Command line --subcategory/--variables take priority over config file values
+	spec := boardSpec{
+		Game:        config.Game,
+		Category:    config.Category,
+		Subcategory: config.Subcategory,
+		Variables:   config.Variables,
+		Merge:       config.Merge,
+		Scoring:     config.Scoring,
+		PerCountry:  config.PerCountry,
 	}
-	fmt.Printf("  Found game: %s (ID: %s)\n", game.Names.International, game.ID)
-
-	var category *models.Category
-	if config.Category != "" {
-		fmt.Printf("Getting category: %s\n", config.Category)
-		cat, err := client.GetCategoryByName(ctx, game.ID, config.Category)
-		if err != nil {
-			return fmt.Errorf("failed to get category: %w", err)
-		}
-		category = cat
-		fmt.Printf("  Found category: %s (ID: %s)\n", category.Name, category.ID)
-	} else {
-		fmt.Println("Getting game categories...")
-		categories, err := client.GetCategories(ctx, game.ID)
-		if err != nil {
-			return fmt.Errorf("failed to get categories: %w", err)
-		}
-		fmt.Printf("  Found %d categories\n", len(categories))
-
-		cat, err := api.SelectCategory(categories)
-		if err != nil {
-			return fmt.Errorf("failed to select category: %w", err)
-		}
-		category = cat
-	}
-
-	// Get game variables
-	variables, err := client.GetVariables(ctx, game.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get variables: %w", err)
-	}
-
-	// Count subcategory variables for this category
-	var subcategoryVars []models.Variable
-	for _, v := range variables {
-		if v.IsSubcategory && (v.Category == "" || v.Category == category.ID) {
-			subcategoryVars = append(subcategoryVars, v)
-		}
-	}
-	hasSubcategories := len(subcategoryVars) > 0
-
-	// Determine variable filters to use
-	selectedVars := make(map[string]string)
-
-	// Priority 1: Command line --subcategory (value only, auto-match "Subcategory" variable)
 	if subcategoryValue != "" {
-		fmt.Printf("Resolving subcategory from command line: %s\n", subcategoryValue)
-		resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, subcategoryValue)
-		if err != nil {
-			return fmt.Errorf("failed to resolve subcategory: %w", err)
-		}
-		selectedVars = resolved
-		fmt.Printf("  Resolved to: %v\n", selectedVars)
-	} else if config.Subcategory != "" {
-		// Priority 2: Config file subcategory (value only, auto-match "Subcategory" variable)
-		fmt.Printf("Resolving subcategory from config: %s\n", config.Subcategory)
-		resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, config.Subcategory)
-		if err != nil {
-			return fmt.Errorf("failed to resolve subcategory: %w", err)
-		}
-		selectedVars = resolved
-		fmt.Printf("  Resolved to: %v\n", selectedVars)
-	} else if len(varFilters) > 0 {
-		// Priority 3: Command line --variables (ID-based)
-		selectedVars = varFilters
-		fmt.Printf("Using command line specified variables: %v\n", selectedVars)
-	} else if len(config.Variables) > 0 {
-		// Priority 4: Config file variables (ID-based)
-		selectedVars = config.Variables
-		fmt.Printf("Using config file specified variables: %v\n", selectedVars)
-	} else if hasSubcategories {
-		// Priority 5: Interactive selection or defaults
-		if isInteractive() {
-			fmt.Println("\nDetected subcategory options...")
-			selectedVars = api.SelectSubcategories(variables, category.ID)
-			// If only one subcategory variable, resolve it via subcategory field
-			if len(subcategoryVars) == 1 && len(selectedVars) > 0 {
-				// Extract value label and use subcategory resolution
-				for varID, valID := range selectedVars {
-					for _, v := range subcategoryVars {
-						if v.ID == varID {
-							if val, ok := v.Values.Values[valID]; ok {
-								subcategoryValue = val.Label
-								fmt.Printf("Resolving single subcategory variable: %s = %s\n", v.Name, val.Label)
-							}
-							break
-						}
-					}
-					if subcategoryValue != "" {
-						break
-					}
-				}
-				// Resolve subcategory by value
-				resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, subcategoryValue)
-				if err != nil {
-					return fmt.Errorf("failed to resolve subcategory: %w", err)
-				}
-				selectedVars = resolved
-				fmt.Printf("  Resolved to: %v\n", selectedVars)
-			}
-		} else {
-			// Non-interactive: use defaults
-			for _, v := range variables {
-				if v.IsSubcategory && (v.Category == "" || v.Category == category.ID) && v.Values.Default != "" {
-					selectedVars[v.ID] = v.Values.Default
-				}
-			}
-			if len(selectedVars) > 0 {
-				fmt.Printf("Using default subcategory values\n")
-			}
-			// If only one subcategory variable, also resolve via subcategory field for consistency
-			if len(subcategoryVars) == 1 && len(selectedVars) > 0 {
-				for varID, valID := range selectedVars {
-					for _, v := range subcategoryVars {
-						if v.ID == varID {
-							if val, ok := v.Values.Values[valID]; ok {
-								subcategoryValue = val.Label
-								// Re-resolve via subcategory field
-								resolved, err := client.ResolveSubcategoryByValue(ctx, game.ID, category.ID, subcategoryValue)
-								if err != nil {
-									return fmt.Errorf("failed to resolve subcategory: %w", err)
-								}
-								selectedVars = resolved
-							}
-							break
-						}
-					}
-					break
-				}
-			}
-		}
+		spec.Subcategory = subcategoryValue
 	}
-
-	// Create cache key
-	cacheKey := &cache.CacheKey{
-		GameID:       game.ID,
-		GameName:     game.Names.International,
-		CategoryID:   category.ID,
-		CategoryName: category.Name,
-		Variables:    selectedVars,
+	if len(varFilters) > 0 {
+		spec.Variables = varFilters
 	}
 
-	var leaderboard *models.LeaderboardData
-
-	// Check if using cache
-	if refreshCache {
-		// Force refresh
-		fmt.Println("Force refresh mode: Fetching latest data...")
-		leaderboard, err = client.GetLeaderboard(ctx, game.ID, category.ID, selectedVars)
-		if err != nil {
-			return fmt.Errorf("failed to get leaderboard: %w", err)
-		}
-		// Save cache
-		if err := saveToCache(lbCache, cacheKey, game, category, leaderboard, playerCache); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
-		} else {
-			fmt.Println("✓ Cache updated")
-		}
-	} else if useCache {
-		// Force use cache
-		if !lbCache.Exists(cacheKey) {
-			return fmt.Errorf("cache does not exist, please run once to create cache")
-		}
-		fmt.Println("Use cache mode: Loading cached data...")
-		cachedData, err := lbCache.Load(cacheKey)
-		if err != nil {
-			return fmt.Errorf("failed to load cache: %w", err)
-		}
-
-		// Collect all player IDs that need to be fetched
-		playerIDs := make(map[string]bool)
-		for _, run := range cachedData.Runs {
-			for _, p := range run.Run.Players {
-				if p.Rel == "user" {
-					playerIDs[p.ID] = true
-				}
-			}
-		}
-
-		// Fill player data: first from cache, then from API if cache miss
-		// Preserve existing Players from leaderboard cache (contains country_code)
-		// CSV cache (country_code) takes priority over playerCache (JSON)
-		leaderboardPlayers := cachedData.Players
-		cachedData.Players = make(map[string]models.PlayerData)
-
-		for playerID := range playerIDs {
-			// Start with leaderboard cache data as base (has country_code from CSV)
-			var basePlayer models.PlayerData
-			hasLbData := false
-			if lbPlayer, found := leaderboardPlayers[playerID]; found {
-				basePlayer = lbPlayer
-				hasLbData = true
-			}
-
-			// Try to get full data from playerCache (JSON) for name style etc
-			if playerCache != nil {
-				if data, found := playerCache.Get(playerID); found {
-					// Always use country_code from leaderboard cache (CSV) as priority
-					if hasLbData && basePlayer.Location != nil && basePlayer.Location.Country != nil {
-						// Use CSV country_code, override playerCache
-						if data.Location == nil {
-							data.Location = &models.Location{}
-						}
-						data.Location.Country = basePlayer.Location.Country
-					}
-					cachedData.Players[playerID] = *data
-					continue
-				}
-			}
-
-			// No playerCache data, use leaderboard cache data or fetch from API
-			if hasLbData {
-				// Use leaderboard cache data (has at least country_code)
-				cachedData.Players[playerID] = basePlayer
-				continue
-			}
-
-			// Cache miss, fetch from API
-			fmt.Printf("  Fetching player data: %s\n", playerID)
-			playerData, err := client.GetUser(ctx, playerID)
-			if err == nil {
-				cachedData.Players[playerID] = *playerData
-				// Save to cache
-				if playerCache != nil {
-					playerCache.Set(playerID, *playerData)
-				}
-			} else {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to fetch player %s: %v\n", playerID, err)
-			}
-		}
-
-		// Save cache to file
-		if playerCache != nil {
-			if err := playerCache.Save(); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
-			}
-		}
-
-		leaderboard = &models.LeaderboardData{
-			Game:      cachedData.Game.ID,
-			Category:  cachedData.Category.ID,
-			Weblink:   "",
-			Runs:      cachedData.Runs,
-			Players:   models.PlayersField{M: cachedData.Players},
-		}
-		cacheTime, _ := lbCache.GetCacheTime(cacheKey)
-		fmt.Printf("✓ Loaded cache (cache time: %s)\n", cacheTime.Format("2006-01-02 15:04:05"))
-	} else {
-		// Auto mode: check cache and prompt
-		if lbCache.Exists(cacheKey) && isInteractive() {
-			cacheTime, _ := lbCache.GetCacheTime(cacheKey)
-			fmt.Printf("\nFound local cache (cache time: %s)\n", cacheTime.Format("2006-01-02 15:04:05"))
-			if confirm("Use cached data?") {
-				cachedData, err := lbCache.Load(cacheKey)
-				if err != nil {
-					return fmt.Errorf("failed to load cache: %w", err)
-				}
-
-				// Collect all player IDs that need to be fetched
-				playerIDs := make(map[string]bool)
-				for _, run := range cachedData.Runs {
-					for _, p := range run.Run.Players {
-						if p.Rel == "user" {
-							playerIDs[p.ID] = true
-						}
-					}
-				}
-
-				// Fill player data: first from cache, then from API if cache miss
-				// Preserve existing Players from leaderboard cache (contains country_code)
-				// CSV cache (country_code) takes priority over playerCache (JSON)
-				leaderboardPlayers := cachedData.Players
-				cachedData.Players = make(map[string]models.PlayerData)
-
-				for playerID := range playerIDs {
-					// Start with leaderboard cache data as base (has country_code from CSV)
-					var basePlayer models.PlayerData
-					hasLbData := false
-					if lbPlayer, found := leaderboardPlayers[playerID]; found {
-						basePlayer = lbPlayer
-						hasLbData = true
-					}
-
-					// Try to get full data from playerCache (JSON) for name style etc
-					if playerCache != nil {
-						if data, found := playerCache.Get(playerID); found {
-							// Always use country_code from leaderboard cache (CSV) as priority
-							if hasLbData && basePlayer.Location != nil && basePlayer.Location.Country != nil {
-								// Use CSV country_code, override playerCache
-								if data.Location == nil {
-									data.Location = &models.Location{}
-								}
-								data.Location.Country = basePlayer.Location.Country
-							}
-							cachedData.Players[playerID] = *data
-							continue
-						}
-					}
-
-					// No playerCache data, use leaderboard cache data or fetch from API
-					if hasLbData {
-						// Use leaderboard cache data (has at least country_code)
-						cachedData.Players[playerID] = basePlayer
-						continue
-					}
-
-					// Cache miss, fetch from API
-					fmt.Printf("  Fetching player data: %s\n", playerID)
-					playerData, err := client.GetUser(ctx, playerID)
-					if err == nil {
-						cachedData.Players[playerID] = *playerData
-						// Save to cache
-						if playerCache != nil {
-							playerCache.Set(playerID, *playerData)
-						}
-					} else {
-						fmt.Fprintf(os.Stderr, "Warning: Failed to fetch player %s: %v\n", playerID, err)
-					}
-				}
-
-				// Save cache to file
-				if playerCache != nil {
-					if err := playerCache.Save(); err != nil {
-						fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
-					}
-				}
-
-				leaderboard = &models.LeaderboardData{
-					Game:      cachedData.Game.ID,
-					Category:  cachedData.Category.ID,
-					Weblink:   "",
-					Runs:      cachedData.Runs,
-					Players:   models.PlayersField{M: cachedData.Players},
-				}
-				fmt.Println("✓ Using cached data")
-			} else {
-				fmt.Println("Fetching latest data...")
-				leaderboard, err = client.GetLeaderboard(ctx, game.ID, category.ID, selectedVars)
-				if err != nil {
-					return fmt.Errorf("failed to get leaderboard: %w", err)
-				}
-				// Save cache
-				if err := saveToCache(lbCache, cacheKey, game, category, leaderboard, playerCache); err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
-				} else {
-					fmt.Println("✓ Data cached")
-				}
-			}
-		} else {
-			// No cache or no stdin, fetch directly
-			fmt.Println("Fetching leaderboard data...")
-			leaderboard, err = client.GetLeaderboard(ctx, game.ID, category.ID, selectedVars)
-			if err != nil {
-				return fmt.Errorf("failed to get leaderboard: %w", err)
-			}
-			// Save cache
-			if err := saveToCache(lbCache, cacheKey, game, category, leaderboard, playerCache); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to save cache: %v\n", err)
-			} else {
-				fmt.Println("✓ Data cached")
-			}
-		}
+	board, err := s.fetchBoard(ctx, spec)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("  Got %d records\n", len(leaderboard.Runs))
-
 	fmt.Println("Generating page...")
-	gen, err := generator.NewGenerator(templatePath, config.CountryCodeMap)
+	gen, err := newGenerator(config, templatePath, s.storage)
 	if err != nil {
-		return fmt.Errorf("failed to create generator: %w", err)
+		return err
 	}
 
 	outputPath := config.Output
@@ -854,28 +781,34 @@ func run(ctx context.Context, config models.Config, timeout time.Duration, varFi
 		outputPath = "./output/index.html"
 	}
 
-	data := &generator.LeaderboardData{
-		Game:         *game,
-		Category:     *category,
-		Leaderboard: *leaderboard,
-			Players:      leaderboard.Players.M,
+	if err := s.renderBoard(gen, config, board, outputPath, nil); err != nil {
+		return err
 	}
-
-	if err := gen.Generate(outputPath, data); err != nil {
-		return fmt.Errorf("failed to generate page: %w", err)
+	s.pages++
+	s.checkClaims(ctx, config.TimeFormat)
+	s.notifier.send(ctx)
+	if err := s.writeBoardJSON(config, board, outputPath); err != nil {
+		return err
 	}
-
-	return nil
+	if err := s.writeHighlights(gen, config, board, outputPath); err != nil {
+		return err
+	}
+	if err := s.writePlayerFiles(); err != nil {
+		return err
+	}
+	return s.staleErr()
 }
 
-func saveToCache(lbCache *cache.LeaderboardCache, key *cache.CacheKey, game *models.Game, category *models.Category, leaderboard *models.LeaderboardData, playerCache *cache.PlayerCache) error {
+func saveToCache(lbCache *cache.LeaderboardCache, key *cache.CacheKey, game *models.Game, category *models.Category, leaderboard *models.LeaderboardData, playerCache *cache.PlayerCache, cachedAt time.Time) error {
 	cachedData := &cache.CachedLeaderboard{
 		Key:       *key,
-		CachedAt:  time.Now(),
+		CachedAt:  cachedAt,
 		Game:      *game,
 		Category:  *category,
 		Runs:      leaderboard.Runs,
 		Players:   make(map[string]models.PlayerData),
+		Platforms: leaderboard.Platforms.M,
+		Weblink:   leaderboard.Weblink,
 	}
 
 	// Collect all player data
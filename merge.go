@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/soar/sr_exhibit/diff"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// fetchMerged fetches the board of every subcategory value of spec.Merge and merges them into one board:
+// each player keeps their best run (highest score on score boards) across the values, places are recomputed, and every run is labeled
+// with the value it comes from
+func (s *session) fetchMerged(ctx context.Context, spec boardSpec) (*boardResult, error) {
+	if spec.Subcategory != "" || len(spec.Variables) > 0 {
+		return nil, withExitCode(exitConfig, fmt.Errorf("merge can't be combined with subcategory or variables"))
+	}
+
+	var boards []*boardResult
+	for _, value := range spec.Merge {
+		fmt.Printf("Merging subcategory: %s\n", value)
+		part := spec
+		part.Merge = nil
+		part.Subcategory = value
+		board, err := s.fetchBoard(ctx, part)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", value, err)
+		}
+		if board.Subcategory == "" {
+			board.Subcategory = value
+		}
+		boards = append(boards, board)
+	}
+	return mergeBoards(boards), nil
+}
+
+// mergeBoards merges boards of the same category into one
+// The merged board has its own cache key, e.g. variable "v1=a1+a2", so it gets its own snapshots
+func mergeBoards(boards []*boardResult) *boardResult {
+	first := boards[0]
+	key := *first.Key
+	key.Variables = make(map[string]string)
+	merged := &boardResult{
+		Key:      &key,
+		Game:     first.Game,
+		Category: first.Category,
+		Sources:  make(map[string]string),
+		Score:    first.Score,
+	}
+	leaderboard := *first.Leaderboard
+	leaderboard.Players.M = make(map[string]models.PlayerData)
+	leaderboard.Platforms.M = make(map[string]string)
+
+	// Best run of each player, with the subcategory it comes from
+	type sourcedRun struct {
+		entry  models.RunEntry
+		source string
+	}
+	var labels []string
+	best := make(map[string]sourcedRun) // Player key -> best run
+	for _, board := range boards {
+		labels = append(labels, board.Subcategory)
+		for varID, valID := range board.Key.Variables {
+			if prev, ok := key.Variables[varID]; !ok {
+				key.Variables[varID] = valID
+			} else if !containsValue(prev, valID) {
+				key.Variables[varID] = prev + "+" + valID
+			}
+		}
+		if board.FromCache {
+			merged.FromCache = true
+			if merged.CachedAt.IsZero() || board.CachedAt.Before(merged.CachedAt) {
+				merged.CachedAt = board.CachedAt
+			}
+		}
+		merged.Fallback = merged.Fallback || board.Fallback
+
+		for id, pd := range board.Leaderboard.Players.M {
+			leaderboard.Players.M[id] = pd
+		}
+		for id, name := range board.Leaderboard.Platforms.M {
+			leaderboard.Platforms.M[id] = name
+		}
+		for _, run := range board.Leaderboard.Runs {
+			playerKey := run.Run.PlayerKey()
+			if prev, ok := best[playerKey]; ok && !diff.Better(run.Run.Times.PrimaryT, prev.entry.Run.Times.PrimaryT, merged.Score) {
+				continue
+			}
+			best[playerKey] = sourcedRun{entry: run, source: board.Subcategory}
+		}
+	}
+	merged.Subcategory = strings.Join(labels, " + ")
+
+	runs := make([]models.RunEntry, 0, len(best))
+	for _, run := range best {
+		runs = append(runs, run.entry)
+		merged.Sources[run.entry.Run.ID] = run.source
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		if ti, tj := timefmt.Millis(runs[i].Run.Times.PrimaryT), timefmt.Millis(runs[j].Run.Times.PrimaryT); ti != tj {
+			return diff.Better(runs[i].Run.Times.PrimaryT, runs[j].Run.Times.PrimaryT, merged.Score)
+		}
+		if runs[i].Run.Date != runs[j].Run.Date {
+			return runs[i].Run.Date < runs[j].Run.Date
+		}
+		return runs[i].Run.ID < runs[j].Run.ID
+	})
+	renumberPlaces(runs)
+	leaderboard.Runs = runs
+	merged.Leaderboard = &leaderboard
+	return merged
+}
+
+// renumberPlaces numbers the places of runs ordered by value, equal values share a place as on speedrun.com
+func renumberPlaces(runs []models.RunEntry) {
+	for i := range runs {
+		if i > 0 && timefmt.Millis(runs[i].Run.Times.PrimaryT) == timefmt.Millis(runs[i-1].Run.Times.PrimaryT) {
+			runs[i].Place = runs[i-1].Place
+		} else {
+			runs[i].Place = i + 1
+		}
+	}
+}
+
+// containsValue reports whether a "+"-joined list of value IDs contains id
+func containsValue(list, id string) bool {
+	for _, v := range strings.Split(list, "+") {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,10 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/soar/sr_exhibit/timefmt"
 )
 
 // APIResponse is a generic API response wrapper
@@ -45,6 +49,18 @@ type Game struct {
 	Assets       GameAssets `json:"assets"`
 }
 
+// URL returns the game page on speedrun.com: the weblink, or the page built from the abbreviation
+// Empty if neither is known
+func (g Game) URL() string {
+	if g.WebLink != "" {
+		return g.WebLink
+	}
+	if g.Abbreviation == "" {
+		return ""
+	}
+	return SiteURL + "/" + url.PathEscape(g.Abbreviation)
+}
+
 // Category represents a game category
 type Category struct {
 	ID   string `json:"id"`
@@ -75,7 +91,15 @@ type RunData struct {
 	Comment   string            `json:"comment"`
 	Date      string            `json:"date"`
 	SubmitURL string            `json:"submit"`
-	Values    map[string]string `json:"values"` // Subcategory variable values
+	Weblink   string            `json:"weblink"` // Run page on speedrun.com, see URL
+	Values    map[string]string `json:"values"`  // Subcategory variable values
+	System    RunSystem         `json:"system"`
+}
+
+// RunSystem represents the platform a run was played on
+type RunSystem struct {
+	Platform string `json:"platform"` // Platform ID, named by LeaderboardData.Platforms
+	Emulated bool   `json:"emulated"`
 }
 
 // Player represents player information
@@ -85,14 +109,103 @@ type Player struct {
 	Name string `json:"name,omitempty"`
 }
 
+// Key returns a stable identifier for the player: the user ID, or "guest:<name>" for guests
+func (p Player) Key() string {
+	if p.Rel == "user" {
+		return p.ID
+	}
+	return "guest:" + p.Name
+}
+
+// SiteURL is the speedrun.com site, the target of the links built for data without a weblink
+const SiteURL = "https://www.speedrun.com"
+
+// URL returns the run page on speedrun.com: the run's weblink, or for runs without one (e.g. loaded
+// from caches written before weblinks were recorded) the page built from the game abbreviation
+// Empty if neither is known
+func (r RunData) URL(game Game) string {
+	if r.Weblink != "" {
+		return r.Weblink
+	}
+	if r.ID == "" || game.Abbreviation == "" {
+		return ""
+	}
+	return SiteURL + "/" + url.PathEscape(game.Abbreviation) + "/run/" + url.PathEscape(r.ID)
+}
+
+// PlayerKey returns a stable identifier for the run's players, joining multiple players with "+"
+func (r RunData) PlayerKey() string {
+	keys := make([]string, 0, len(r.Players))
+	for _, p := range r.Players {
+		keys = append(keys, p.Key())
+	}
+	return strings.Join(keys, "+")
+}
+
 // RunTimes represents time data
 type RunTimes struct {
-	Primary   string  `json:"primary"`
-	PrimaryT  float64 `json:"primary_t"`
-	Realtime  *string `json:"realtime,omitempty"`
-	RealtimeT *float64 `json:"realtime_t,omitempty"`
-	GameTime  *string `json:"gametime,omitempty"`
-	GameTimeT *float64 `json:"gametime_t,omitempty"`
+	Primary          string   `json:"primary"`
+	PrimaryT         float64  `json:"primary_t"`
+	Realtime         *string  `json:"realtime,omitempty"`
+	RealtimeT        *float64 `json:"realtime_t,omitempty"`
+	GameTime         *string  `json:"gametime,omitempty"`
+	GameTimeT        *float64 `json:"gametime_t,omitempty"`
+	RealtimeNoloads  *string  `json:"realtime_noloads,omitempty"`
+	RealtimeNoloadsT *float64 `json:"realtime_noloads_t,omitempty"`
+}
+
+// Timing methods supported by the leaderboard API
+const (
+	TimingRealtime        = "realtime"
+	TimingRealtimeNoloads = "realtime_noloads"
+	TimingIngame          = "ingame"
+)
+
+// ValidTiming reports whether the timing method is supported, empty means the game's primary timing
+func ValidTiming(timing string) bool {
+	switch timing {
+	case "", TimingRealtime, TimingRealtimeNoloads, TimingIngame:
+		return true
+	}
+	return false
+}
+
+// Scoring modes: how run values are shown
+const (
+	ScoringTime  = "time"  // Values are durations
+	ScoringScore = "score" // Values are scores (points, attempts) submitted as seconds, shown as whole numbers
+)
+
+// ValidScoring reports whether the scoring mode is supported, empty means detected from the category
+func ValidScoring(scoring string) bool {
+	switch scoring {
+	case "", ScoringTime, ScoringScore:
+		return true
+	}
+	return false
+}
+
+// UseTiming replaces the primary time with the given timing method, if the run has it
+// Returns false if the run has no time for that method
+func (t *RunTimes) UseTiming(timing string) bool {
+	var iso *string
+	var seconds *float64
+	switch timing {
+	case TimingRealtime:
+		iso, seconds = t.Realtime, t.RealtimeT
+	case TimingRealtimeNoloads:
+		iso, seconds = t.RealtimeNoloads, t.RealtimeNoloadsT
+	case TimingIngame:
+		iso, seconds = t.GameTime, t.GameTimeT
+	default:
+		return true
+	}
+	if iso == nil || seconds == nil || *seconds <= 0 {
+		return false
+	}
+	t.Primary = *iso
+	t.PrimaryT = *seconds
+	return true
 }
 
 // RunVideos represents video links
@@ -168,11 +281,43 @@ func (p *PlayersField) MarshalJSON() ([]byte, error) {
 
 // LeaderboardData represents leaderboard data
 type LeaderboardData struct {
-	Game      string        `json:"game"`      // Game ID
-	Category  string        `json:"category"`  // Category ID
-	Weblink   string        `json:"weblink"`
+	Game      string         `json:"game"`     // Game ID
+	Category  string         `json:"category"` // Category ID
+	Weblink   string         `json:"weblink"`
 	Runs      []RunEntry     `json:"runs"`
-	Players   PlayersField  `json:"players"`
+	Players   PlayersField   `json:"players"`
+	Platforms PlatformsField `json:"platforms"` // Platform names of the runs
+}
+
+// PlatformsField maps platform IDs to names, embedded as {"data": [...]} by the API
+type PlatformsField struct {
+	M map[string]string
+}
+
+// UnmarshalJSON implements json.Unmarshaler for PlatformsField, accepting {"data": [...]} or a plain map
+func (p *PlatformsField) UnmarshalJSON(data []byte) error {
+	var platformsObj struct {
+		Data []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &platformsObj); err == nil && platformsObj.Data != nil {
+		p.M = make(map[string]string, len(platformsObj.Data))
+		for _, platform := range platformsObj.Data {
+			p.M[platform.ID] = platform.Name
+		}
+		return nil
+	}
+	if err := json.Unmarshal(data, &p.M); err != nil {
+		return fmt.Errorf("unable to parse platforms field")
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler for PlatformsField
+func (p *PlatformsField) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.M)
 }
 
 // PlayerData represents detailed player data
@@ -184,7 +329,20 @@ type PlayerData struct {
 	Names     struct {
 		International string `json:"international"`
 	} `json:"names,omitempty"`
-	Location  *Location  `json:"location,omitempty"`
+	Location *Location `json:"location,omitempty"`
+	Weblink  string    `json:"weblink,omitempty"` // Profile on speedrun.com, see URL
+}
+
+// URL returns the player's profile on speedrun.com: the weblink, or the profile built from the name
+// for players without one (e.g. loaded from the leaderboard cache); empty without a name
+func (p PlayerData) URL() string {
+	if p.Weblink != "" {
+		return p.Weblink
+	}
+	if p.Names.International == "" {
+		return ""
+	}
+	return SiteURL + "/user/" + url.PathEscape(p.Names.International)
 }
 
 // Location represents user location
@@ -218,21 +376,227 @@ type NameStyleColor struct {
 
 // Config represents config file structure
 type Config struct {
-	Game           string            `yaml:"game"`
-	Category       string            `yaml:"category"`
-	Output         string            `yaml:"output"`
-	Template       string            `yaml:"template"`       // Custom template file path
-	API            APIConfig         `yaml:"api"`
-	Cache          CacheConfig       `yaml:"cache"`          // Cache configuration
-	Variables      map[string]string `yaml:"variables"`      // Variable filters (ID-based)
-	Subcategory    string            `yaml:"subcategory"`    // Subcategory filter (format: "Name:Value")
-	CountryCodeMap map[string]string `yaml:"countryCodeMap"` // Country code replacement rules, e.g. {"xk": "rs"} for Kosovo -> Serbia
+	Game           string              `yaml:"game"`
+	Category       string              `yaml:"category"`
+	Output         string              `yaml:"output"`
+	Template       string              `yaml:"template"` // Custom template file path
+	API            APIConfig           `yaml:"api"`
+	Cache          CacheConfig         `yaml:"cache"`          // Cache configuration
+	Variables      map[string]string   `yaml:"variables"`      // Variable filters (ID-based)
+	Subcategory    string              `yaml:"subcategory"`    // Subcategory filter (format: "Name:Value")
+	Merge          []string            `yaml:"merge"`          // Subcategory values merged into one board, each player's best run across them
+	Scoring        string              `yaml:"scoring"`        // "time" or "score", default detected from the category name
+	PerCountry     int                 `yaml:"perCountry"`     // Only the best N runs of each country, an international championship table (0: full board)
+	CountryCodeMap map[string]string   `yaml:"countryCodeMap"` // Country code replacement rules, e.g. {"xk": "rs"} for Kosovo -> Serbia
+	Display        DisplayConfig       `yaml:"display"`        // Page display options
+	TimeFormat     timefmt.Options     `yaml:"timeFormat"`     // Fractional seconds display options
+	Leaderboards   []LeaderboardConfig `yaml:"leaderboards"`   // Batch mode: generate several leaderboards and a hub page
+	Defaults       LeaderboardDefaults `yaml:"defaults"`       // Batch mode: settings applied to every leaderboard entry
+	AssetsDir      string              `yaml:"assetsDir"`      // Directory overriding embedded assets (see --export-assets)
+	Hub            HubConfig           `yaml:"hub"`            // Hub page configuration (batch mode)
+	Compare        CompareConfig       `yaml:"compare"`        // Compare page configuration (compare mode)
+	Page           PageConfig          `yaml:"page"`           // Page title, description and header overrides
+	Events         []EventConfig       `yaml:"events"`         // Named date ranges, runs inside them are tagged
+	Aliases        map[string]string   `yaml:"aliases"`        // Player ID or old name -> display name, for renamed players
+	Workers        int                 `yaml:"workers"`        // Batch mode: pages rendered in parallel, default the number of CPUs
+	Storage        StorageConfig       `yaml:"storage"`        // Where generated pages are written (local files or S3)
+	Export         ExportConfig        `yaml:"export"`         // Machine-readable files written next to the pages
+	Minify         MinifyConfig        `yaml:"minify"`         // Page minification options
+	StatsFile      string              `yaml:"statsFile"`      // JSONL file the statistics of each run are appended to (optional, never sent anywhere)
+	Notifications  []NotificationRule  `yaml:"notifications"`  // Rules sending leaderboard changes to webhooks or files
+	Schedule       ScheduleConfig      `yaml:"schedule"`       // Quiet hours of scheduled runs
+	Highlights     HighlightsConfig    `yaml:"highlights"`     // Highlight reel page rotating through featured runs
+	Publish        PublishConfig       `yaml:"publish"`        // Deploy targets the output directory is published to after generation
+	Claims         ClaimsConfig        `yaml:"claims"`         // Cross-checking new world records before they are announced
+	Exhibition     ExhibitionConfig    `yaml:"exhibition"`     // Event mini-site of featured boards, set up in one block
+}
+
+// ExhibitionConfig represents an exhibition event (a marathon, a tournament): one block generating the landing
+// page, board pages, highlight reels and podium cards of its featured boards, all in the event's look
+type ExhibitionConfig struct {
+	Name      string              `yaml:"name"`      // Event name, title of the landing page and tag of the runs done during the event
+	Start     string              `yaml:"start"`     // First day, format "2006-01-02"
+	End       string              `yaml:"end"`       // Last day (inclusive), format "2006-01-02"
+	Boards    []LeaderboardConfig `yaml:"boards"`    // Featured boards, entries as in leaderboards
+	Players   []string            `yaml:"players"`   // Featured players (user IDs or names), marked on every page
+	OutputDir string              `yaml:"outputDir"` // Root of the mini-site, default "./output"
+	Branding  BrandingConfig      `yaml:"branding"`  // Logo, theme and assets of every page
+}
+
+// Enabled reports whether an exhibition is configured
+func (e ExhibitionConfig) Enabled() bool {
+	return e.Name != "" || len(e.Boards) > 0
+}
+
+// BrandingConfig represents the look of an exhibition mini-site
+type BrandingConfig struct {
+	Logo      string `yaml:"logo"`      // Image on the landing page and podium cards (SVG, PNG, JPEG, GIF or WebP)
+	Theme     string `yaml:"theme"`     // Theme of every page, overrides display.theme
+	AssetsDir string `yaml:"assetsDir"` // Directory overriding embedded assets, e.g. the event theme CSS; overrides assetsDir
+}
+
+// PublishConfig represents the deploy targets of the output directory
+type PublishConfig struct {
+	IPFS IPFSConfig `yaml:"ipfs"`
+}
+
+// IPFSConfig represents publishing the output directory to IPFS through a local node
+type IPFSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	API     string `yaml:"api"` // RPC API of the IPFS (Kubo) node, default "http://127.0.0.1:5001"
+	Dir     string `yaml:"dir"` // Directory published, default the output directory
+}
+
+// HighlightsConfig represents the highlight reel written next to every board page (<page>-highlights.html)
+type HighlightsConfig struct {
+	Enabled bool `yaml:"enabled"` // Write the highlight reel
+	Runs    int  `yaml:"runs"`    // Top runs in the rotation, runs of the last 30 days are added; default 20
+	Seconds int  `yaml:"seconds"` // Seconds each run is shown, default 8
+}
+
+// ClaimsConfig represents the cross-checking of new world records (claims mode)
+type ClaimsConfig struct {
+	Verify bool `yaml:"verify"` // Check new world records against the records endpoint before announcing them
+}
+
+// ScheduleConfig represents when scheduled runs may regenerate pages
+type ScheduleConfig struct {
+	QuietHours string `yaml:"quietHours"` // Daily window without generation or notifications, e.g. "02:00-08:00"
+	Timezone   string `yaml:"timezone"`   // IANA time zone of quietHours, e.g. "Europe/Berlin"; default local time
+}
+
+// NotificationRule represents leaderboard changes to notify and where to send them
+// Every condition set must hold; a rule without conditions matches every change
+type NotificationRule struct {
+	Name           string               `yaml:"name"`           // Shown in messages and warnings, default "rule <n>"
+	Changes        []string             `yaml:"changes"`        // Change kinds: new_wr, time_save, places_gained, new_entry; default all
+	MaxPlace       int                  `yaml:"maxPlace"`       // Only changes to this place or better, e.g. 3 for the podium; 0 for any
+	MinImprovement string               `yaml:"minImprovement"` // Minimum time saved, e.g. "10s"; new entries never match
+	Players        []string             `yaml:"players"`        // Player names or user IDs, any player of a multiplayer run matches
+	Countries      []string             `yaml:"countries"`      // Country codes of the player, e.g. "jp"
+	Targets        []NotificationTarget `yaml:"targets"`        // Where matching changes are sent
+}
+
+// NotificationTarget represents one destination of a notification rule, either a webhook or a file
+type NotificationTarget struct {
+	Webhook string `yaml:"webhook"` // URL receiving a JSON POST with the message as "content" (Discord) and "text" (Slack)
+	File    string `yaml:"file"`    // File the messages are appended to, one line each
+}
+
+// EventConfig represents a named date range, e.g. an event's qualifying period
+type EventConfig struct {
+	Name  string `yaml:"name"`  // Label shown next to the run date
+	Start string `yaml:"start"` // First day, format "2006-01-02"
+	End   string `yaml:"end"`   // Last day (inclusive), format "2006-01-02"
+}
+
+// LeaderboardConfig represents one leaderboard entry in batch mode
+type LeaderboardConfig struct {
+	Game        string            `yaml:"game"`
+	Category    string            `yaml:"category"`
+	Subcategory string            `yaml:"subcategory"` // Subcategory filter (value-based)
+	Variables   map[string]string `yaml:"variables"`   // Variable filters (ID-based)
+	Merge       []string          `yaml:"merge"`       // Subcategory values merged into one board, each player's best run across them
+	Output      string            `yaml:"output"`      // Output file path, default "./output/<game>-<category>.html"
+	Template    string            `yaml:"template"`    // Custom template file path, overrides top-level template
+	Timing      string            `yaml:"timing"`      // Timing method: realtime, realtime_noloads or ingame (default: game's primary timing)
+	Top         int               `yaml:"top"`         // Number of places to fetch (default 100)
+	Scoring     string            `yaml:"scoring"`     // "time" or "score", default detected from the category name
+	PerCountry  int               `yaml:"perCountry"`  // Only the best N runs of each country, an international championship table (0: full board)
+	Page        PageConfig        `yaml:"page"`        // Page overrides, each field overrides the top-level page block
+}
+
+// PageConfig represents overrides of the generated page's title, description and header
+type PageConfig struct {
+	Title       string `yaml:"title"`       // Page title, default "<Game> - <Category> Leaderboard"
+	Description string `yaml:"description"` // Meta description, also shown under the header
+	HeaderHTML  string `yaml:"headerHTML"`  // Raw HTML replacing the game header (cover, title, links)
+}
+
+// Merge returns p with its empty fields taken from base
+func (p PageConfig) Merge(base PageConfig) PageConfig {
+	if p.Title == "" {
+		p.Title = base.Title
+	}
+	if p.Description == "" {
+		p.Description = base.Description
+	}
+	if p.HeaderHTML == "" {
+		p.HeaderHTML = base.HeaderHTML
+	}
+	return p
+}
+
+// LeaderboardDefaults represents settings applied to every leaderboard entry in batch mode
+// Each field is overridden by the matching field of an entry
+type LeaderboardDefaults struct {
+	Template  string `yaml:"template"`  // Custom template file path
+	OutputDir string `yaml:"outputDir"` // Root directory of generated pages, default "./output"
+	Timing    string `yaml:"timing"`    // Timing method
+	Top       int    `yaml:"top"`       // Number of places to fetch
+}
+
+// HubConfig represents hub page configuration
+type HubConfig struct {
+	Output   string `yaml:"output"`   // Hub page path, default "./output/index.html"
+	Title    string `yaml:"title"`    // Hub page title, default "Leaderboards"
+	Template string `yaml:"template"` // Custom hub template file path (see --export-template)
+	Search   bool   `yaml:"search"`   // Search box over the players and boards of the batch
+}
+
+// CompareConfig represents compare page configuration
+type CompareConfig struct {
+	Template string `yaml:"template"` // Custom compare template file path (see --export-template)
+}
+
+// DisplayConfig represents page display options
+type DisplayConfig struct {
+	RankMovement bool   `yaml:"rankMovement"` // Show ▲/▼ places gained/lost since the previous snapshot
+	ShowGaps     bool   `yaml:"showGaps"`     // Show "+Gap" column: delta to the run above and to the world record
+	Theme        string `yaml:"theme"`        // Page theme: "dark" (default), "light" or a theme file name in assetsDir/themes
+	Locale       string `yaml:"locale"`       // UI language: "en" (default), "zh" or a locale file name in assetsDir/locales
+	Flags        string `yaml:"flags"`        // Country flags: "sprite" (default, inline SVG) or "remote" (speedrun.com PNGs)
+	VisibleRows  int    `yaml:"visibleRows"`  // Rows shown before a "Show all" expander, 0 (default) shows every row
+	StaleAfter   string `yaml:"staleAfter"`   // Age of cached data after which a "Standings as of" banner is shown, default "24h", "0" disables
+	Ranking      string `yaml:"ranking"`      // Place numbering of equal times: "standard" (default, 1, 1, 3) or "dense" (1, 1, 2)
+	RankOffset   int    `yaml:"rankOffset"`   // Added to every place, e.g. 100 for a page continuing at 101
+	HideRanks    bool   `yaml:"hideRanks"`    // Don't show places at all, for unranked showcase lists
+	Tiebreak     string `yaml:"tiebreak"`     // Order of runs sharing a place: "" (as on speedrun.com), "name" or "date" (earliest first)
+	Collation    string `yaml:"collation"`    // Language of the name order (BCP 47, e.g. "ja"), default the page locale
+	Sparkline    int    `yaml:"sparkline"`    // Snapshots shown in a rank sparkline next to each place, 0 (default) disables
+	Heatmap      string `yaml:"heatmap"`      // Rows of players who improved recently get a background fading out over this duration, e.g. "72h"; empty disables
 }
 
 // APIConfig represents API configuration
 type APIConfig struct {
 	BaseURL string `yaml:"baseURL"`
 	Timeout string `yaml:"timeout"`
+	Retries *int   `yaml:"retries"` // Retries of a failed leaderboard fetch, default 2
+}
+
+// MinifyConfig represents the minification options of generated pages
+type MinifyConfig struct {
+	KeepComments bool `yaml:"keepComments"` // Keep HTML comments
+	JSVersion    int  `yaml:"jsVersion"`    // ECMAScript version of inline scripts: 5 (ES5) or a year, default 2022
+	SkipCSS      bool `yaml:"skipCSS"`      // Don't minify inline CSS
+	SkipJS       bool `yaml:"skipJS"`       // Don't minify inline scripts
+}
+
+// ExportConfig represents the machine-readable files written next to the pages
+type ExportConfig struct {
+	JSON    bool `yaml:"json"`    // Write <page>.json for every board, and manifest.json next to the hub page in batch mode
+	Players bool `yaml:"players"` // Write players/<id>.json next to the pages, with the runs of each player on the boards
+	Changes bool `yaml:"changes"` // Batch mode: write changes.json next to the hub page, the pages and runs changed since the previous batch
+}
+
+// StorageConfig represents where generated pages are written
+// Output paths are kept as object keys below the prefix, e.g. "./output/index.html" -> "<prefix>/output/index.html"
+type StorageConfig struct {
+	Type     string `yaml:"type"`     // "fs" (default) or "s3"
+	Bucket   string `yaml:"bucket"`   // S3 bucket
+	Prefix   string `yaml:"prefix"`   // Key prefix inside the bucket
+	Region   string `yaml:"region"`   // S3 region, default AWS_REGION or "us-east-1"
+	Endpoint string `yaml:"endpoint"` // S3-compatible endpoint (e.g. R2, MinIO), path-style addressing; default AWS
 }
 
 // CacheConfig represents cache configuration
@@ -240,6 +604,17 @@ type CacheConfig struct {
 	Enabled bool   `yaml:"enabled"` // Whether to enable cache, default true
 	Dir     string `yaml:"dir"`     // Cache directory, default ".cache"
 	TTL     string `yaml:"ttl"`     // Cache expiration time, default "720h" (30 days)
+	// Fallback controls the use of cached data when the live fetch fails:
+	// "on" (default) uses it, "stale" uses it and exits with code 9, "off" fails
+	Fallback string `yaml:"fallback"`
+	// Snapshots is the retention of the snapshot archive, applied after every generation; empty keeps every snapshot
+	Snapshots SnapshotsConfig `yaml:"snapshots"`
+}
+
+// SnapshotsConfig represents the retention of the snapshot archive, the latest snapshot of a board is always kept
+type SnapshotsConfig struct {
+	KeepLast   int `yaml:"keepLast"`   // Most recent snapshots kept per board
+	KeepWeekly int `yaml:"keepWeekly"` // Weeks back from now whose last snapshot is kept, e.g. 52 for a year
 }
 
 // Variable represents game variable (subcategory)
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/diff"
+	"github.com/soar/sr_exhibit/models"
+	"github.com/soar/sr_exhibit/timefmt"
+)
+
+// claimMismatch is the change kind of the alerts about new world records the records endpoint disagrees with,
+// see checkClaims
+const claimMismatch diff.Kind = "claim_mismatch"
+
+// notificationRule is a validated notification rule of the config
+type notificationRule struct {
+	name           string
+	kinds          map[diff.Kind]bool // Empty for every kind
+	maxPlace       int
+	minImprovement float64 // Seconds
+	players        []string
+	countries      map[string]bool // Lowercase country codes, empty for any
+	targets        []models.NotificationTarget
+}
+
+// notification is a message waiting to be sent to the targets of a rule
+type notification struct {
+	rule    *notificationRule
+	message string
+	change  diff.Change
+}
+
+// notifier evaluates the notification rules against the changes of every generated board,
+// then sends the matching messages once the pages are written
+type notifier struct {
+	rules   []*notificationRule
+	opts    timefmt.Options
+	client  *http.Client
+	clock   clock.Clock // Time of the file target lines
+	pending []notification
+}
+
+// newNotifier validates the notification rules of the config
+func newNotifier(config models.Config, timeout time.Duration) (*notifier, error) {
+	n := &notifier{opts: config.TimeFormat, client: &http.Client{Timeout: timeout}, clock: clock.System}
+	for i, r := range config.Notifications {
+		rule := &notificationRule{
+			name:      r.Name,
+			kinds:     make(map[diff.Kind]bool),
+			maxPlace:  r.MaxPlace,
+			players:   r.Players,
+			countries: make(map[string]bool),
+			targets:   r.Targets,
+		}
+		if rule.name == "" {
+			rule.name = fmt.Sprintf("rule %d", i+1)
+		}
+		for _, kind := range r.Changes {
+			if !diff.ValidKind(diff.Kind(kind)) && diff.Kind(kind) != claimMismatch {
+				return nil, fmt.Errorf("notifications: %s: unknown change %q (use %s, %s, %s, %s or %s)",
+					rule.name, kind, diff.NewWR, diff.TimeSave, diff.PlacesGained, diff.NewEntry, claimMismatch)
+			}
+			rule.kinds[diff.Kind(kind)] = true
+		}
+		if r.MaxPlace < 0 {
+			return nil, fmt.Errorf("notifications: %s: maxPlace must not be negative", rule.name)
+		}
+		if r.MinImprovement != "" {
+			d, err := time.ParseDuration(r.MinImprovement)
+			if err != nil {
+				return nil, fmt.Errorf("notifications: %s: invalid minImprovement: %w", rule.name, err)
+			}
+			rule.minImprovement = d.Seconds()
+		}
+		for _, code := range r.Countries {
+			rule.countries[strings.ToLower(code)] = true
+		}
+		if len(r.Targets) == 0 {
+			return nil, fmt.Errorf("notifications: %s: no targets", rule.name)
+		}
+		for _, t := range r.Targets {
+			if (t.Webhook == "") == (t.File == "") {
+				return nil, fmt.Errorf("notifications: %s: each target needs either webhook or file", rule.name)
+			}
+		}
+		n.rules = append(n.rules, rule)
+	}
+	return n, nil
+}
+
+// collect queues a message for every rule matching a change of a board
+// players is the board's player data, for the country conditions
+func (n *notifier) collect(changes []diff.Change, players map[string]models.PlayerData, score bool) {
+	for _, rule := range n.rules {
+		for _, c := range changes {
+			if rule.matches(c, players) {
+				n.pending = append(n.pending, notification{rule: rule, message: n.message(c, score), change: c})
+			}
+		}
+	}
+}
+
+// withhold drops the queued new world record messages of a change
+func (n *notifier) withhold(c diff.Change) {
+	pending := n.pending[:0]
+	for _, note := range n.pending {
+		if note.change.Kind != diff.NewWR || note.change.Board != c.Board || note.change.RunID != c.RunID {
+			pending = append(pending, note)
+		}
+	}
+	n.pending = pending
+}
+
+// alert queues a claimMismatch message about a new world record for every matching rule
+func (n *notifier) alert(c diff.Change, reason string, players map[string]models.PlayerData) {
+	c.Kind = claimMismatch
+	for _, rule := range n.rules {
+		if rule.matches(c, players) {
+			msg := fmt.Sprintf("World record in %s not verified: %s.", c.Board, reason)
+			n.pending = append(n.pending, notification{rule: rule, message: msg, change: c})
+		}
+	}
+}
+
+// matches reports whether a change meets every condition of the rule
+func (r *notificationRule) matches(c diff.Change, players map[string]models.PlayerData) bool {
+	if len(r.kinds) > 0 && !r.kinds[c.Kind] {
+		return false
+	}
+	if r.maxPlace > 0 && c.NewPlace > r.maxPlace {
+		return false
+	}
+	if r.minImprovement > 0 && (c.OldTime == 0 || c.TimeSaved() < r.minImprovement) {
+		return false
+	}
+
+	runPlayers := splitPlayers(c.PlayerKey, c.PlayerName)
+	if len(r.players) > 0 {
+		found := false
+		for _, p := range runPlayers {
+			for _, want := range r.players {
+				if p.key == want || strings.EqualFold(p.name, want) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.countries) > 0 {
+		found := false
+		for _, p := range runPlayers {
+			if pd, ok := players[p.key]; ok && pd.Location != nil && pd.Location.Country != nil {
+				found = found || r.countries[strings.ToLower(pd.Location.Country.Code)]
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// message describes a change in one sentence
+func (n *notifier) message(c diff.Change, score bool) string {
+	value := func(v float64) string { return timefmt.FormatSeconds(v, n.opts) }
+	gap := func(saved float64) string { return timefmt.FormatDelta(-saved, n.opts) }
+	if score {
+		value = timefmt.FormatScore
+		gap = timefmt.FormatScoreDelta // Points gained are positive
+	}
+
+	switch c.Kind {
+	case diff.NewWR:
+		msg := fmt.Sprintf("New world record! %s set %s in %s", c.PlayerName, value(c.NewTime), c.Board)
+		if c.PrevHolder != "" {
+			msg += fmt.Sprintf(", beating the previous record by %s (%s)", c.PrevHolder, gap(c.TimeSaved()))
+		}
+		return msg + "."
+	case diff.TimeSave:
+		return fmt.Sprintf("%s improved by %s to %s in %s (now #%d).", c.PlayerName, gap(c.TimeSaved()), value(c.NewTime), c.Board, c.NewPlace)
+	case diff.PlacesGained:
+		places := "places"
+		if c.PlacesGained() == 1 {
+			places = "place"
+		}
+		return fmt.Sprintf("%s climbed %d %s to #%d in %s.", c.PlayerName, c.PlacesGained(), places, c.NewPlace, c.Board)
+	default:
+		return fmt.Sprintf("%s entered %s at #%d with %s.", c.PlayerName, c.Board, c.NewPlace, value(c.NewTime))
+	}
+}
+
+// webhookPayload is the JSON body posted to webhook targets
+// The message is sent as "content" and "text", so Discord and Slack webhooks accept it as is
+type webhookPayload struct {
+	Content string        `json:"content"`
+	Text    string        `json:"text"`
+	Rule    string        `json:"rule"`
+	Change  webhookChange `json:"change"`
+}
+
+// webhookChange is the change of a webhook payload, for receivers formatting their own messages
+type webhookChange struct {
+	Kind       diff.Kind `json:"kind"`
+	Board      string    `json:"board"`
+	Player     string    `json:"player"`
+	RunID      string    `json:"run_id"`
+	OldPlace   int       `json:"old_place,omitempty"`
+	NewPlace   int       `json:"new_place"`
+	OldTime    float64   `json:"old_time,omitempty"`
+	NewTime    float64   `json:"new_time"`
+	PrevHolder string    `json:"prev_holder,omitempty"`
+}
+
+// send delivers the queued messages to their targets
+// A failed target only prints a warning, notifications never fail the run
+// The summary counts deliveries: a message for two targets counts twice
+func (n *notifier) send(ctx context.Context) {
+	sent, failed := 0, 0
+	for _, note := range n.pending {
+		for _, t := range note.rule.targets {
+			var err error
+			if t.Webhook != "" {
+				err = n.post(ctx, t.Webhook, note)
+			} else {
+				err = appendLine(t.File, n.clock.Now().UTC().Format(time.RFC3339)+" "+note.message)
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "Warning: Notification %s: %v\n", note.rule.name, err)
+				continue
+			}
+			sent++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("Notifications: %d sent, %d failed\n", sent, failed)
+	} else if sent > 0 {
+		fmt.Printf("Notifications: %d sent\n", sent)
+	}
+	n.pending = nil
+}
+
+// post sends a notification to a webhook
+func (n *notifier) post(ctx context.Context, url string, note notification) error {
+	c := note.change
+	body, err := json.Marshal(webhookPayload{
+		Content: note.message,
+		Text:    note.message,
+		Rule:    note.rule.name,
+		Change: webhookChange{
+			Kind:       c.Kind,
+			Board:      c.Board,
+			Player:     c.PlayerName,
+			RunID:      c.RunID,
+			OldPlace:   c.OldPlace,
+			NewPlace:   c.NewPlace,
+			OldTime:    c.OldTime,
+			NewTime:    c.NewTime,
+			PrevHolder: c.PrevHolder,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// appendLine appends a line to a file, creating it and its directory if needed
+func appendLine(path, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
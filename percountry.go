@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+// topPerCountry derives an "international championship" board from a full board: the best n runs of each
+// country, with places recomputed among them
+// A run counts for the country of its first player with one; runs without a country are left out.
+// The derived board has its own cache key, e.g. "percountry=1", so it gets its own snapshots.
+func topPerCountry(board *boardResult, n int) *boardResult {
+	key := *board.Key
+	key.PerCountry = n
+	derived := *board
+	derived.Key = &key
+	derived.Subcategory = perCountryLabel(board.Subcategory, n)
+
+	leaderboard := *board.Leaderboard
+	players := leaderboard.Players.M
+	counts := make(map[string]int) // Country code -> runs kept
+	runs := make([]models.RunEntry, 0, len(leaderboard.Runs))
+	for _, run := range leaderboard.Runs {
+		code := runCountry(run.Run, players)
+		if code == "" || counts[code] >= n {
+			continue
+		}
+		counts[code]++
+		runs = append(runs, run)
+	}
+	renumberPlaces(runs)
+	leaderboard.Runs = runs
+	derived.Leaderboard = &leaderboard
+	return &derived
+}
+
+// runCountry returns the lowercase country code of the first player of a run who has one, or ""
+func runCountry(run models.RunData, players map[string]models.PlayerData) string {
+	for _, p := range run.Players {
+		if pd, ok := players[p.ID]; ok && p.Rel == "user" && pd.Location != nil && pd.Location.Country != nil && pd.Location.Country.Code != "" {
+			return strings.ToLower(pd.Location.Country.Code)
+		}
+	}
+	return ""
+}
+
+// perCountryLabel appends the per-country mode to the subcategory labels of a board,
+// e.g. "GCN" -> "GCN, best per country" or "top 3 per country" without subcategory
+func perCountryLabel(subcategory string, n int) string {
+	label := "best per country"
+	if n > 1 {
+		label = fmt.Sprintf("top %d per country", n)
+	}
+	if subcategory == "" {
+		return label
+	}
+	return subcategory + ", " + label
+}
@@ -0,0 +1,160 @@
+// Package progress reports the progress of long operations: a bar redrawn in place on
+// interactive terminals, periodic log lines otherwise (scheduled tasks, CI logs)
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// barWidth is the number of characters of the progress bar
+	barWidth = 30
+	// redrawInterval limits how often a terminal bar is redrawn
+	redrawInterval = 100 * time.Millisecond
+	// logInterval is how often a progress line is logged on non-interactive outputs
+	logInterval = 5 * time.Second
+)
+
+var spinner = []string{"|", "/", "-", "\\"}
+
+// output is where the tasks of a context report to
+type output struct {
+	mu          sync.Mutex
+	w           io.Writer
+	interactive bool
+}
+
+type contextKey struct{}
+
+// NewContext returns a context whose tasks report to w
+// Bars are drawn if w is a terminal, plain lines are logged otherwise
+func NewContext(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, contextKey{}, &output{w: w, interactive: isTerminal(w)})
+}
+
+// isTerminal reports whether w is an interactive terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Task is the progress of one operation
+// A nil Task, returned for contexts without an output, ignores progress; Fprintf still prints
+type Task struct {
+	out     *output
+	label   string
+	total   int // 0 if unknown, a spinner and count are shown instead of a bar
+	done    int
+	started time.Time
+	shown   time.Time // Last redraw or log line
+	drawn   bool      // A terminal bar is on the current line
+}
+
+// Start starts reporting an operation of total steps (0 if unknown)
+func Start(ctx context.Context, label string, total int) *Task {
+	out, _ := ctx.Value(contextKey{}).(*output)
+	if out == nil {
+		return nil
+	}
+	now := time.Now()
+	t := &Task{out: out, label: label, total: total, started: now, shown: now}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if out.interactive {
+		t.draw()
+	}
+	return t
+}
+
+// Add records n finished steps
+func (t *Task) Add(n int) {
+	if t == nil {
+		return
+	}
+	t.out.mu.Lock()
+	defer t.out.mu.Unlock()
+
+	t.done += n
+	since := time.Since(t.shown)
+	if t.out.interactive && since >= redrawInterval {
+		t.draw()
+	} else if !t.out.interactive && since >= logInterval {
+		fmt.Fprintf(t.out.w, "%s: %s\n", t.label, t.count())
+		t.shown = time.Now()
+	}
+}
+
+// Fprintf prints a message line to w (e.g. os.Stderr for warnings) without garbling the bar
+func (t *Task) Fprintf(w io.Writer, format string, args ...any) {
+	if t == nil {
+		fmt.Fprintf(w, format+"\n", args...)
+		return
+	}
+	t.out.mu.Lock()
+	defer t.out.mu.Unlock()
+
+	if t.drawn {
+		fmt.Fprint(t.out.w, "\r\033[K")
+		t.drawn = false
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+	if t.out.interactive {
+		t.draw()
+	}
+}
+
+// Finish ends the operation, leaving a final line with the step count and duration
+func (t *Task) Finish() {
+	if t == nil {
+		return
+	}
+	t.out.mu.Lock()
+	defer t.out.mu.Unlock()
+
+	if t.total == 0 && t.done == 0 {
+		if t.drawn {
+			fmt.Fprint(t.out.w, "\r\033[K")
+		}
+		return
+	}
+	elapsed := time.Since(t.started).Round(100 * time.Millisecond)
+	if t.out.interactive {
+		t.draw()
+		fmt.Fprintf(t.out.w, " (%s)\n", elapsed)
+	} else {
+		fmt.Fprintf(t.out.w, "%s: %s (%s)\n", t.label, t.count(), elapsed)
+	}
+	t.drawn = false
+}
+
+// draw redraws the terminal line of the task, the output lock must be held
+func (t *Task) draw() {
+	var line string
+	if t.total > 0 {
+		filled := barWidth * min(t.done, t.total) / t.total
+		line = fmt.Sprintf("%s [%s%s] %s", t.label, strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled), t.count())
+	} else {
+		line = fmt.Sprintf("%s %s %s", t.label, spinner[t.done%len(spinner)], t.count())
+	}
+	fmt.Fprint(t.out.w, "\r\033[K"+line)
+	t.drawn = true
+	t.shown = time.Now()
+}
+
+// count returns the finished steps, with the total and percentage if known
+func (t *Task) count() string {
+	if t.total > 0 {
+		return fmt.Sprintf("%d/%d (%d%%)", t.done, t.total, 100*t.done/t.total)
+	}
+	return fmt.Sprintf("%d", t.done)
+}
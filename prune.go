@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/clock"
+	"github.com/soar/sr_exhibit/models"
+)
+
+// snapshotRetention converts the retention of the config
+func snapshotRetention(config models.SnapshotsConfig) cache.Retention {
+	return cache.Retention{KeepLast: config.KeepLast, KeepWeekly: config.KeepWeekly}
+}
+
+// validateRetention checks the snapshot retention of the config
+func validateRetention(config models.SnapshotsConfig) error {
+	if config.KeepLast < 0 || config.KeepWeekly < 0 {
+		return fmt.Errorf("cache.snapshots: keepLast and keepWeekly must not be negative")
+	}
+	return nil
+}
+
+// pruneSnapshots deletes the snapshots outside the retention policy of the config at the time of clk, if any
+func pruneSnapshots(snapshots *cache.SnapshotStore, config models.SnapshotsConfig, clk clock.Clock) error {
+	deleted, err := snapshots.Prune(snapshotRetention(config), clk.Now())
+	if err != nil {
+		return fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	if deleted > 0 {
+		fmt.Printf("Snapshots: Pruned %d old snapshots\n", deleted)
+	}
+	return nil
+}
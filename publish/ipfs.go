@@ -0,0 +1,148 @@
+// Package publish publishes the generated output directory to deploy targets
+package publish
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultIPFSAPI is the RPC API address of a local IPFS (Kubo) node
+	DefaultIPFSAPI = "http://127.0.0.1:5001"
+	// ipfsTimeout is the timeout of adding the whole directory
+	ipfsTimeout = 10 * time.Minute
+)
+
+// IPFS adds directories to an IPFS node through its RPC API (Kubo's /api/v0)
+type IPFS struct {
+	client *http.Client
+	api    string
+}
+
+// NewIPFS creates a client of the IPFS node at api, DefaultIPFSAPI if empty
+func NewIPFS(api string) (*IPFS, error) {
+	if api == "" {
+		api = DefaultIPFSAPI
+	}
+	u, err := url.Parse(api)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid publish.ipfs.api %q (e.g. %s)", api, DefaultIPFSAPI)
+	}
+	return &IPFS{client: &http.Client{Timeout: ipfsTimeout}, api: strings.TrimRight(api, "/")}, nil
+}
+
+// addResult is one line of the response of /api/v0/add
+type addResult struct {
+	Name string
+	Hash string
+}
+
+// AddDir adds and pins a directory with everything below it, returning the CID of the directory
+// Files are streamed to the node, the directory is never held in memory
+func (c *IPFS) AddDir(ctx context.Context, dir string) (string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	body, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		w.CloseWithError(writeDir(form, dir))
+	}()
+
+	query := url.Values{"pin": {"true"}, "cid-version": {"1"}, "quieter": {"true"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.api+"/api/v0/add?"+query.Encode(), body)
+	if err != nil {
+		body.Close()
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		body.Close()
+		return "", fmt.Errorf("failed to reach the IPFS node: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("IPFS node returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	// One JSON object per line; with quieter only the directory itself, which comes last
+	var root addResult
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var r addResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return "", fmt.Errorf("invalid IPFS node response: %w", err)
+		}
+		root = r
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if root.Hash == "" {
+		return "", fmt.Errorf("IPFS node returned no CID")
+	}
+	return root.Hash, nil
+}
+
+// writeDir writes the directory as the multipart form of /api/v0/add: a part for every directory
+// and file, named by its slash-separated path starting with the directory name
+func writeDir(form *multipart.Writer, dir string) error {
+	root := filepath.Base(filepath.Clean(dir))
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(root, filepath.ToSlash(rel))
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, url.QueryEscape(name)))
+		if d.IsDir() {
+			header.Set("Content-Type", "application/x-directory")
+			_, err := form.CreatePart(header)
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		header.Set("Content-Type", "application/octet-stream")
+		part, err := form.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(part, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return form.Close()
+}
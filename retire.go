@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/soar/sr_exhibit/cache"
+	"github.com/soar/sr_exhibit/generator"
+)
+
+// retiredRedirectSeconds is the delay before a retired page redirects to the nearest surviving page
+const retiredRedirectSeconds = 10
+
+// recordPages adds the leaderboard pages generated by a batch run to the page index
+func recordPages(index *cache.PageIndex, boards []batchBoard) {
+	for _, board := range boards {
+		r := board.result
+		index.Set(filepath.Clean(board.output), cache.PageRecord{
+			GameID:       r.Game.ID,
+			GameName:     r.Game.Names.International,
+			CategoryID:   r.Category.ID,
+			CategoryName: r.Category.Name,
+			Subcategory:  r.Subcategory,
+			Variables:    r.Key.Variables,
+		})
+	}
+}
+
+// retirePages replaces the pages of earlier runs whose category or subcategory value was removed upstream
+// by a notice redirecting to the nearest surviving page: the same category, the same game, or the hub page.
+// boards are the leaderboards generated by this run; pages only missing from this run
+// (removed from the config, failed fetch) are left alone.
+func (s *session) retirePages(ctx context.Context, gen *generator.Generator, index *cache.PageIndex, boards []batchBoard, hubOutput, hubTitle string) {
+	generated := make(map[string]bool, len(boards))
+	for _, board := range boards {
+		generated[filepath.Clean(board.output)] = true
+	}
+
+	for _, output := range index.Outputs() {
+		record, _ := index.Get(output)
+		if record.Retired || generated[output] {
+			continue
+		}
+		reason, gone := s.removedUpstream(ctx, record)
+		if !gone {
+			continue
+		}
+
+		link, linkTitle := hubOutput, hubTitle
+		if board, ok := nearestBoard(boards, record); ok {
+			link = board.output
+			linkTitle = board.result.Category.Name
+			if board.result.Subcategory != "" {
+				linkTitle += " (" + board.result.Subcategory + ")"
+			}
+		}
+		data := &generator.RetiredData{
+			Title:           fmt.Sprintf("%s - %s", record.GameName, record.CategoryName),
+			GameName:        record.GameName,
+			CategoryName:    record.CategoryName,
+			Subcategory:     record.Subcategory,
+			Link:            relativeLink(output, link),
+			LinkTitle:       linkTitle,
+			RedirectSeconds: retiredRedirectSeconds,
+		}
+		if err := gen.GenerateRetired(output, data); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to retire %s: %v\n", output, err)
+			continue
+		}
+		record.Retired = true
+		index.Set(output, record)
+		s.pages++
+		fmt.Printf("  ✓ %s (retired: %s)\n", output, reason)
+	}
+}
+
+// removedUpstream reports whether the category or a subcategory value of a page no longer exists
+// API failures report nothing removed, a page is only retired on certain knowledge
+func (s *session) removedUpstream(ctx context.Context, record cache.PageRecord) (string, bool) {
+	categories, err := s.client.GetCategories(ctx, record.GameID)
+	if err != nil {
+		return "", false
+	}
+	found := false
+	for _, c := range categories {
+		if c.ID == record.CategoryID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "category removed", true
+	}
+	if len(record.Variables) == 0 {
+		return "", false
+	}
+
+	variables, err := s.client.GetVariables(ctx, record.GameID)
+	if err != nil {
+		return "", false
+	}
+	for varID, valIDs := range record.Variables {
+		// Merged boards list their values joined by "+"
+		for _, valID := range strings.Split(valIDs, "+") {
+			exists := false
+			for _, v := range variables {
+				if v.ID == varID {
+					_, exists = v.Values.Values[valID]
+					break
+				}
+			}
+			if !exists {
+				return "subcategory value removed", true
+			}
+		}
+	}
+	return "", false
+}
+
+// nearestBoard returns the board of this run closest to a retired page: same category first, then same game
+func nearestBoard(boards []batchBoard, record cache.PageRecord) (batchBoard, bool) {
+	var sameGame *batchBoard
+	for i, board := range boards {
+		if board.result.Game.ID != record.GameID {
+			continue
+		}
+		if board.result.Category.ID == record.CategoryID {
+			return board, true
+		}
+		if sameGame == nil {
+			sameGame = &boards[i]
+		}
+	}
+	if sameGame != nil {
+		return *sameGame, true
+	}
+	return batchBoard{}, false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zones of schedule.timezone also resolve on systems without a zoneinfo database
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+// quietHours is a daily time window in which scheduled runs generate nothing and send no notifications
+type quietHours struct {
+	start, end time.Duration // Since midnight; end before start wraps past midnight
+	loc        *time.Location
+	window     string // As configured, e.g. "02:00-08:00"
+}
+
+// parseQuietHours parses schedule.quietHours in schedule.timezone (default local time)
+// Returns nil if no quiet hours are configured
+func parseQuietHours(schedule models.ScheduleConfig) (*quietHours, error) {
+	if schedule.QuietHours == "" {
+		if schedule.Timezone != "" {
+			return nil, fmt.Errorf("schedule.timezone is set without schedule.quietHours")
+		}
+		return nil, nil
+	}
+	q := &quietHours{loc: time.Local, window: schedule.QuietHours}
+	if schedule.Timezone != "" {
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule.timezone: %w", err)
+		}
+		q.loc = loc
+	}
+
+	from, to, ok := strings.Cut(schedule.QuietHours, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid schedule.quietHours %q (format: \"02:00-08:00\")", schedule.QuietHours)
+	}
+	var err error
+	if q.start, err = parseClock(from); err != nil {
+		return nil, fmt.Errorf("invalid schedule.quietHours start: %w", err)
+	}
+	if q.end, err = parseClock(to); err != nil {
+		return nil, fmt.Errorf("invalid schedule.quietHours end: %w", err)
+	}
+	if q.start == q.end {
+		return nil, fmt.Errorf("schedule.quietHours %q is empty", schedule.QuietHours)
+	}
+	return q, nil
+}
+
+// parseClock parses a time of day, e.g. "08:30" -> 8h30m
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", strings.TrimSpace(s))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains reports whether t falls inside the quiet hours, the start included and the end excluded
+func (q *quietHours) contains(t time.Time) bool {
+	local := t.In(q.loc)
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	if q.start < q.end {
+		return clock >= q.start && clock < q.end
+	}
+	return clock >= q.start || clock < q.end
+}
+
+// String describes the quiet hours with their time zone, e.g. "02:00-08:00 Europe/Berlin"
+func (q *quietHours) String() string {
+	return q.window + " " + q.loc.String()
+}
@@ -0,0 +1,206 @@
+// Package schema describes Go data types, generated by reflection so it always matches the code:
+// as a JSON Schema, or as a markdown field reference for template authors
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Naming selects how the fields of a struct are named
+type Naming int
+
+const (
+	// GoNames names fields as Go and templates do, e.g. {{.Game.Names.International}}
+	GoNames Naming = iota
+	// JSONNames names fields by their json struct tags, as in JSON files
+	JSONNames
+)
+
+// Root is a documented type, e.g. the data of one page template
+type Root struct {
+	Name string       // e.g. "leaderboard.html"
+	Type reflect.Type // Type of the data
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// builder collects the definitions of the named struct types reached from the roots
+type builder struct {
+	naming Naming
+	defs   map[string]map[string]any
+	types  map[string]reflect.Type // Definition name -> type, for the markdown reference
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) of the roots
+// Named struct types are definitions under $defs, each root is referenced from "properties"
+func JSONSchema(title, version string, naming Naming, roots []Root) map[string]any {
+	b := &builder{naming: naming, defs: make(map[string]map[string]any), types: make(map[string]reflect.Type)}
+	properties := make(map[string]any)
+	for _, root := range roots {
+		properties[root.Name] = b.schema(root.Type)
+	}
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       title,
+		"description": fmt.Sprintf("Generated by sr_exhibit %s", version),
+		"type":        "object",
+		"properties":  properties,
+		"$defs":       b.defs,
+	}
+}
+
+// schema returns the schema of a type, adding named struct types to the definitions
+func (b *builder) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if b.naming == JSONNames && (t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType)) {
+		// Custom JSON encoding, the Go structure doesn't describe it
+		return map[string]any{"description": fmt.Sprintf("%s, custom JSON encoding", t)}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{b.schema(t.Elem()), map[string]any{"type": "null"}}}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := defName(t)
+		if _, ok := b.defs[name]; !ok {
+			b.defs[name] = nil // Placeholder for recursive types
+			b.types[name] = t
+			b.defs[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]any{} // Any value (interfaces)
+	}
+}
+
+// object returns the schema of a struct's fields
+func (b *builder) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	for _, f := range b.fields(t) {
+		properties[f.name] = b.schema(f.typ)
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// field is a documented struct field
+type field struct {
+	name string
+	typ  reflect.Type
+}
+
+// fields returns the exported fields of a struct with their documented names
+// Embedded structs are flattened, fields tagged json:"-" are left out of JSON names
+func (b *builder) fields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, b.fields(f.Type)...)
+			continue
+		}
+		name := f.Name
+		if b.naming == JSONNames {
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, field{name: name, typ: f.Type})
+	}
+	return fields
+}
+
+// defName returns the definition name of a named type, e.g. "models.Game"
+func defName(t reflect.Type) string {
+	return t.String()
+}
+
+// Markdown returns a field reference of the roots: their fields, then every named struct type they reach
+func Markdown(title, version string, naming Naming, roots []Root) string {
+	b := &builder{naming: naming, defs: make(map[string]map[string]any), types: make(map[string]reflect.Type)}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\nGenerated by sr_exhibit %s.\n", title, version)
+
+	for _, root := range roots {
+		b.schema(root.Type)
+		fmt.Fprintf(&sb, "\n## %s\n\nData: [`%s`](#%s)\n", root.Name, root.Type, anchor(defName(root.Type)))
+	}
+
+	names := make([]string, 0, len(b.types))
+	for name := range b.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := b.types[name]
+		fmt.Fprintf(&sb, "\n## %s\n\n| Field | Type |\n|-------|------|\n", name)
+		b.writeFields(&sb, "", t)
+		if methods := templateMethods(t); naming == GoNames && len(methods) > 0 {
+			fmt.Fprintf(&sb, "\nMethods: %s\n", strings.Join(methods, ", "))
+		}
+	}
+	return sb.String()
+}
+
+// writeFields writes the table rows of a struct's fields
+// Fields of unnamed structs are listed with their path, e.g. "Names.International"
+func (b *builder) writeFields(sb *strings.Builder, prefix string, t reflect.Type) {
+	for _, f := range b.fields(t) {
+		if f.typ.Kind() == reflect.Struct && f.typ.Name() == "" {
+			b.writeFields(sb, prefix+f.name+".", f.typ)
+			continue
+		}
+		fmt.Fprintf(sb, "| %s | `%s` |\n", prefix+f.name, f.typ)
+	}
+}
+
+// anchor returns the link anchor of a markdown heading, e.g. "generator.HubData" -> "generatorhubdata"
+func anchor(heading string) string {
+	return strings.ToLower(strings.ReplaceAll(heading, ".", ""))
+}
+
+// templateMethods returns the methods templates can call on a value without arguments, e.g. "HasBoth() bool"
+func templateMethods(t reflect.Type) []string {
+	var methods []string
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		// The receiver is the first input
+		if m.Type.NumIn() != 1 || m.Type.NumOut() == 0 || m.Type.NumOut() > 2 {
+			continue
+		}
+		methods = append(methods, fmt.Sprintf("`%s() %s`", m.Name, m.Type.Out(0)))
+	}
+	return methods
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FS writes files to the local file system
+// Each file is written to a uniquely named temp file next to its path, then renamed over it,
+// so concurrent writers never share a temp file
+type FS struct{}
+
+// fsFile is a file being written by FS
+type fsFile struct {
+	*os.File
+	path string
+}
+
+// Create creates the parent directories of path and a temp file next to it
+func (FS) Create(path string) (File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	// CreateTemp creates private files, output files are published
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return &fsFile{File: file, path: path}, nil
+}
+
+// Commit closes the temp file and renames it over the output file
+func (f *fsFile) Commit() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to replace output file: %w", err)
+	}
+	return nil
+}
+
+// Abort closes and removes the temp file
+func (f *fsFile) Abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCollision is returned when a run writes the same output file twice
+var ErrCollision = errors.New("output collision")
+
+// Guard claims every path written through it, so two pages configured with the same output fail
+// instead of silently overwriting each other, even when they are rendered in parallel
+// A path stays claimed once created, whether its file is committed or aborted, use one Guard per run
+type Guard struct {
+	st      Storage
+	mu      sync.Mutex
+	claimed map[string]bool // Absolute paths
+}
+
+// NewGuard wraps a storage, safe for concurrent use
+func NewGuard(st Storage) *Guard {
+	return &Guard{st: st, claimed: make(map[string]bool)}
+}
+
+// Create claims path and starts writing it, failing with ErrCollision if it was already claimed
+func (g *Guard) Create(path string) (File, error) {
+	key := filepath.Clean(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+
+	g.mu.Lock()
+	if g.claimed[key] {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s is written twice in this run, set a different output", ErrCollision, path)
+	}
+	g.claimed[key] = true
+	g.mu.Unlock()
+
+	file, err := g.st.Create(path)
+	if err != nil {
+		// Nothing was written, a later attempt may retry
+		g.mu.Lock()
+		delete(g.claimed, key)
+		g.mu.Unlock()
+		return nil, err
+	}
+	return file, nil
+}
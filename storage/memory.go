@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// Memory keeps files in memory, e.g. to serve generated pages without writing them to disk
+// Paths are normalized like object keys, so "./output/index.html" and "output/index.html" are the same file
+type Memory struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemory creates an empty memory storage
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+// memoryFile is a file being written to Memory
+type memoryFile struct {
+	bytes.Buffer
+	m   *Memory
+	key string
+}
+
+// Create starts a file, stored when committed
+func (m *Memory) Create(path string) (File, error) {
+	return &memoryFile{m: m, key: objectKey("", path)}, nil
+}
+
+// Get returns the content of a committed file
+func (m *Memory) Get(path string) ([]byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.files[objectKey("", path)]
+	return data, ok
+}
+
+// Paths returns the paths of the stored files, sorted
+func (m *Memory) Paths() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	paths := make([]string, 0, len(m.files))
+	for p := range m.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Commit stores the file, replacing the previous content
+func (f *memoryFile) Commit() error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.m.files[f.key] = f.Bytes()
+	return nil
+}
+
+// Abort discards the file
+func (f *memoryFile) Abort() {
+	f.Reset()
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+const (
+	// s3Timeout is the timeout of one upload
+	s3Timeout = 2 * time.Minute
+	// defaultS3Region is used when neither storage.region nor AWS_REGION is set
+	defaultS3Region = "us-east-1"
+)
+
+// S3 uploads files to an S3-compatible bucket (AWS S3, Cloudflare R2, MinIO, ...)
+// Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+// A file is uploaded with a single PUT when committed; S3 only shows an object once it is complete.
+type S3 struct {
+	client       *http.Client
+	bucket       string
+	prefix       string
+	region       string
+	endpoint     *url.URL // Custom endpoint with path-style addressing, nil for AWS virtual-hosted addressing
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// NewS3 creates an S3 storage from the config and the AWS environment variables
+func NewS3(config models.StorageConfig) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("storage.bucket is required for %s storage", TypeS3)
+	}
+	s := &S3{
+		client:       &http.Client{Timeout: s3Timeout},
+		bucket:       config.Bucket,
+		prefix:       config.Prefix,
+		region:       config.Region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("%s storage needs the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables", TypeS3)
+	}
+	for _, region := range []string{os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), defaultS3Region} {
+		if s.region == "" {
+			s.region = region
+		}
+	}
+	if config.Endpoint != "" {
+		endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid storage.endpoint %q", config.Endpoint)
+		}
+		s.endpoint = endpoint
+	}
+	return s, nil
+}
+
+// s3File is a file buffered in memory until uploaded
+type s3File struct {
+	bytes.Buffer
+	s   *S3
+	key string
+}
+
+// Create starts a file, uploaded when committed
+func (s *S3) Create(path string) (File, error) {
+	return &s3File{s: s, key: objectKey(s.prefix, path)}, nil
+}
+
+// Commit uploads the file
+func (f *s3File) Commit() error {
+	return f.s.put(f.key, f.Bytes())
+}
+
+// Abort discards the file without uploading it
+func (f *s3File) Abort() {
+	f.Reset()
+}
+
+// objectURL returns the URL of an object
+func (s *S3) objectURL(key string) string {
+	if s.endpoint != nil {
+		return fmt.Sprintf("%s://%s%s/%s/%s", s.endpoint.Scheme, s.endpoint.Host, s.endpoint.EscapedPath(), escapeKey(s.bucket), escapeKey(key))
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escapeKey(key))
+}
+
+// put uploads an object
+func (s *S3) put(key string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, data, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload %s: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request, signing all its headers
+func (s *S3) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hashHex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// escapeKey percent-encodes an object key as S3 expects, keeping the slashes
+func escapeKey(key string) string {
+	var b strings.Builder
+	for _, c := range []byte(key) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hashHex returns the hex-encoded SHA-256 of data
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,58 @@
+// Package storage writes generated files to the local file system, to memory or to S3-compatible object storage
+package storage
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/soar/sr_exhibit/models"
+)
+
+// Storage types (storage.type)
+const (
+	TypeFS = "fs" // Local file system (default)
+	TypeS3 = "s3" // S3-compatible object storage
+)
+
+// Storage writes output files
+// A file replaces the previous file of the same path only once committed, so readers
+// never see a partially written file and a failed generation keeps the previous one
+type Storage interface {
+	// Create starts writing the file at path
+	Create(path string) (File, error)
+}
+
+// File is an output file being written
+// Exactly one of Commit or Abort must be called
+type File interface {
+	io.Writer
+	// Commit publishes the written file, replacing the previous one
+	Commit() error
+	// Abort discards the written data, the previous file is kept
+	Abort()
+}
+
+// New creates the storage selected by the config
+func New(config models.StorageConfig) (Storage, error) {
+	switch config.Type {
+	case "", TypeFS:
+		return FS{}, nil
+	case TypeS3:
+		return NewS3(config)
+	default:
+		return nil, fmt.Errorf("unknown storage.type %q (use %s or %s)", config.Type, TypeFS, TypeS3)
+	}
+}
+
+// objectKey converts an output path to a slash-separated key below prefix
+// e.g. "./output/index.html" with prefix "site" -> "site/output/index.html"
+func objectKey(prefix, p string) string {
+	key := strings.TrimLeft(path.Clean("/"+filepath.ToSlash(p)), "/")
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key
+}
@@ -0,0 +1,198 @@
+package timefmt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// RoundingTruncate drops digits beyond the precision (speedrun.com behavior)
+	RoundingTruncate = "truncate"
+	// RoundingRound rounds to the nearest value at the precision
+	RoundingRound = "round"
+)
+
+// Options controls how fractional seconds are displayed
+type Options struct {
+	Precision    int    `yaml:"precision"`    // Fractional digits (1-3), 0 means auto: 2 digits, 3 if milliseconds are significant
+	Rounding     string `yaml:"rounding"`     // "truncate" (default) or "round"
+	ShowZeroFrac bool   `yaml:"showZeroFrac"` // Show ".00" for whole seconds instead of hiding it
+}
+
+// Validate reports an error for an unknown rounding mode or a precision outside 0-3
+func (o Options) Validate() error {
+	switch o.Rounding {
+	case "", RoundingTruncate, RoundingRound:
+	default:
+		return fmt.Errorf("unknown timeFormat rounding %q (use %s or %s)", o.Rounding, RoundingTruncate, RoundingRound)
+	}
+	if o.Precision < 0 || o.Precision > 3 {
+		return fmt.Errorf("timeFormat precision must be 0 (auto) to 3, got %d", o.Precision)
+	}
+	return nil
+}
+
+// Millis converts seconds to whole milliseconds
+// Rounding to the nearest millisecond removes float artifacts, e.g. 1491.04 is stored as 1491.0399999...
+func Millis(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
+
+// FormatSeconds formats seconds as "H:MM:SS.ff" or "M:SS.ff"
+func FormatSeconds(seconds float64, opts Options) string {
+	negative := seconds < 0
+	ms := Millis(math.Abs(seconds))
+
+	precision := opts.Precision
+	if precision <= 0 {
+		// Auto: show milliseconds only when they carry information
+		precision = 2
+		if ms%10 != 0 {
+			precision = 3
+		}
+	}
+	if precision > 3 {
+		precision = 3
+	}
+
+	// Reduce milliseconds to the requested precision
+	unit := int64(math.Pow10(3 - precision))
+	if opts.Rounding == RoundingRound {
+		ms = (ms + unit/2) / unit * unit
+	} else {
+		ms = ms / unit * unit
+	}
+
+	totalSeconds := ms / 1000
+	frac := (ms % 1000) / unit
+	hours := totalSeconds / 3600
+	minutes := (totalSeconds % 3600) / 60
+	secs := totalSeconds % 60
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	if hours > 0 {
+		fmt.Fprintf(&b, "%d:%02d:%02d", hours, minutes, secs)
+	} else {
+		fmt.Fprintf(&b, "%d:%02d", minutes, secs)
+	}
+	if frac > 0 || opts.ShowZeroFrac {
+		fmt.Fprintf(&b, ".%0*d", precision, frac)
+	}
+	return b.String()
+}
+
+// ParseISO parses an ISO 8601 duration (e.g., "PT16M25.04S") to seconds
+func ParseISO(isoTime string) float64 {
+	remaining := strings.TrimPrefix(isoTime, "PT")
+	remaining = strings.TrimSuffix(remaining, "S")
+
+	var total float64
+
+	// Parse hours
+	if hIdx := strings.Index(remaining, "H"); hIdx > 0 {
+		hours, _ := strconv.Atoi(remaining[:hIdx])
+		total += float64(hours) * 3600
+		remaining = remaining[hIdx+1:]
+	}
+
+	// Parse minutes
+	if mIdx := strings.Index(remaining, "M"); mIdx > 0 {
+		minutes, _ := strconv.Atoi(remaining[:mIdx])
+		total += float64(minutes) * 60
+		remaining = remaining[mIdx+1:]
+	}
+
+	// Parse seconds (may include decimals)
+	if remaining != "" {
+		seconds, _ := strconv.ParseFloat(remaining, 64)
+		total += seconds
+	}
+
+	return float64(Millis(total)) / 1000
+}
+
+// FormatISO formats seconds as an ISO 8601 duration with millisecond precision (e.g., "PT16M25.04S")
+func FormatISO(seconds float64) string {
+	ms := Millis(seconds)
+	if ms <= 0 {
+		return "PT0S"
+	}
+
+	hours := ms / 3600000
+	minutes := (ms % 3600000) / 60000
+	secs := (ms % 60000) / 1000
+	frac := ms % 1000
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+	}
+	if hours > 0 || minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+	}
+	fmt.Fprintf(&b, "%d", secs)
+	if frac > 0 {
+		b.WriteString(strings.TrimRight(fmt.Sprintf(".%03d", frac), "0"))
+	}
+	b.WriteString("S")
+	return b.String()
+}
+
+// FormatCSV formats seconds for the CSV cache, keeping full millisecond precision
+func FormatCSV(seconds float64) string {
+	return strconv.FormatFloat(float64(Millis(seconds))/1000, 'f', -1, 64)
+}
+
+// FormatDelta formats a time difference with an explicit sign, e.g. "+1.50" or "+1:02.50"
+func FormatDelta(seconds float64, opts Options) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+
+	formatted := FormatSeconds(seconds, opts)
+	if Millis(seconds) < 60000 {
+		// Under a minute, drop the "0:" prefix and leading zero: "0:01.50" -> "1.50"
+		formatted = strings.TrimPrefix(formatted, "0:")
+		if len(formatted) > 1 && formatted[0] == '0' && formatted[1] != '.' {
+			formatted = formatted[1:]
+		}
+	}
+	return sign + formatted
+}
+
+// FormatScore formats the value of a score-based category, stored as seconds, as a whole number
+// with thousands separators, e.g. 12345 -> "12,345"
+func FormatScore(value float64) string {
+	score := int64(math.Round(value))
+	sign := ""
+	if score < 0 {
+		sign = "-"
+		score = -score
+	}
+	digits := strconv.FormatInt(score, 10)
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// FormatScoreDelta formats a score difference with an explicit sign, e.g. "+1,500"
+func FormatScoreDelta(value float64) string {
+	if math.Round(value) < 0 {
+		return FormatScore(value)
+	}
+	return "+" + FormatScore(value)
+}
@@ -0,0 +1,161 @@
+package timefmt
+
+import "testing"
+
+func TestFormatSeconds(t *testing.T) {
+	truncate := func(p int) Options { return Options{Precision: p, Rounding: RoundingTruncate} }
+	round := func(p int) Options { return Options{Precision: p, Rounding: RoundingRound} }
+
+	tests := []struct {
+		seconds float64
+		opts    Options
+		want    string
+	}{
+		// Float artifacts: 1491.04 is stored as 1491.0399999...
+		{1491.04, Options{}, "24:51.04"},
+		{1491.04, truncate(2), "24:51.04"},
+		{1491.04, truncate(3), "24:51.040"},
+		{1491.04, truncate(1), "24:51"},
+		{1491.04, Options{Precision: 1, ShowZeroFrac: true}, "24:51.0"},
+
+		// Auto precision shows milliseconds only when significant
+		{1501.999, Options{}, "25:01.999"},
+		{1501.5, Options{}, "25:01.50"},
+		{1501, Options{}, "25:01"},
+		{3725, Options{ShowZeroFrac: true}, "1:02:05.00"},
+
+		// .999 at each precision
+		{1501.999, truncate(3), "25:01.999"},
+		{1501.999, round(3), "25:01.999"},
+		{1501.999, truncate(2), "25:01.99"},
+		{1501.999, round(2), "25:02"},
+		{1501.999, Options{Precision: 2, Rounding: RoundingRound, ShowZeroFrac: true}, "25:02.00"},
+		{1501.999, truncate(1), "25:01.9"},
+		{1501.999, round(1), "25:02"},
+		{1501.995, round(2), "25:02"},
+		{1501.994, round(2), "25:01.99"},
+
+		// Rounding carries into minutes and hours
+		{59.999, truncate(2), "0:59.99"},
+		{59.999, round(2), "1:00"},
+		{3599.999, truncate(1), "59:59.9"},
+		{3599.999, round(1), "1:00:00"},
+		{3599.95, round(1), "1:00:00"},
+
+		{-1.5, Options{}, "-0:01.50"},
+		{0, Options{}, "0:00"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSeconds(tt.seconds, tt.opts); got != tt.want {
+			t.Errorf("FormatSeconds(%v, %+v) = %q, want %q", tt.seconds, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestISORoundTrip(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		iso     string
+	}{
+		{1491.04, "PT24M51.04S"},
+		{1501.999, "PT25M1.999S"},
+		{3725, "PT1H2M5S"},
+		{3600.5, "PT1H0M0.5S"},
+		{59.1, "PT59.1S"},
+		{0, "PT0S"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatISO(tt.seconds); got != tt.iso {
+			t.Errorf("FormatISO(%v) = %q, want %q", tt.seconds, got, tt.iso)
+		}
+		if got := ParseISO(tt.iso); got != tt.seconds {
+			t.Errorf("ParseISO(%q) = %v, want %v", tt.iso, got, tt.seconds)
+		}
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	tests := map[float64]string{
+		1491.04:  "1491.04",
+		1501.999: "1501.999",
+		3725:     "3725",
+	}
+	for seconds, want := range tests {
+		if got := FormatCSV(seconds); got != want {
+			t.Errorf("FormatCSV(%v) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestFormatDelta(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		opts    Options
+		want    string
+	}{
+		{1.5, Options{}, "+1.50"},
+		{0.5, Options{}, "+0.50"},
+		{12.34, Options{}, "+12.34"},
+		{0, Options{}, "+0"},
+		{-62.5, Options{}, "-1:02.50"},
+		{0.999, Options{Precision: 2, Rounding: RoundingRound}, "+1"},
+		{59.999, Options{Precision: 2, Rounding: RoundingTruncate}, "+59.99"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDelta(tt.seconds, tt.opts); got != tt.want {
+			t.Errorf("FormatDelta(%v, %+v) = %q, want %q", tt.seconds, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestFormatScore(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+		delta string
+	}{
+		{0, "0", "+0"},
+		{999, "999", "+999"},
+		{1000, "1,000", "+1,000"},
+		{1234567, "1,234,567", "+1,234,567"},
+		{12344.6, "12,345", "+12,345"},
+		{-1500, "-1,500", "-1,500"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatScore(tt.value); got != tt.want {
+			t.Errorf("FormatScore(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+		if got := FormatScoreDelta(tt.value); got != tt.delta {
+			t.Errorf("FormatScoreDelta(%v) = %q, want %q", tt.value, got, tt.delta)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []Options{
+		{},
+		{Precision: 3, Rounding: RoundingRound},
+		{Precision: 1, Rounding: RoundingTruncate},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", opts, err)
+		}
+	}
+
+	invalid := []Options{
+		{Rounding: "ceil"},
+		{Rounding: "Round"},
+		{Precision: 4},
+		{Precision: -1},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", opts)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// usage holds the statistics of one run, appended to the stats file (statsFile) as a JSON line
+// Nothing is ever sent anywhere, the file is for operators to graph their own runs
+type usage struct {
+	StartedAt      time.Time `json:"started_at"`
+	DurationMS     int64     `json:"duration_ms"`
+	Mode           string    `json:"mode"`            // single, batch or compare
+	Pages          int       `json:"pages"`           // Pages generated, including the hub page
+	Failed         int       `json:"failed"`          // Batch mode: leaderboards that failed
+	APICalls       int64     `json:"api_calls"`       // speedrun.com API requests, cached lookups excluded
+	CacheFallbacks int       `json:"cache_fallbacks"` // Boards generated from cached data after a failed fetch
+	ExitCode       int       `json:"exit_code"`
+	Version        string    `json:"version"`
+}
+
+type usageKey struct{}
+
+// withUsage returns a context collecting the statistics of the run into u
+func withUsage(ctx context.Context, u *usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+// recordUsage adds the statistics of a session to the run's usage, if collected
+func (s *session) recordUsage(ctx context.Context, mode string) {
+	u, _ := ctx.Value(usageKey{}).(*usage)
+	if u == nil {
+		return
+	}
+	u.Mode = mode
+	u.Pages += s.pages
+	u.Failed += s.failed
+	u.APICalls += s.client.Calls()
+	u.CacheFallbacks += s.fellBack
+}
+
+// writeUsage appends the statistics of a finished run to the stats file, if configured
+// A failure to write only prints a warning, the run itself is not affected
+func writeUsage(path string, u *usage, err error) {
+	if path == "" {
+		return
+	}
+	u.DurationMS = time.Since(u.StartedAt).Milliseconds()
+	u.ExitCode = exitCode(err)
+
+	line, jsonErr := json.Marshal(u)
+	if jsonErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to encode usage stats: %v\n", jsonErr)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to write usage stats: %v\n", err)
+		return
+	}
+	// One write of a whole line in append mode, so overlapping runs don't interleave lines
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to write usage stats: %v\n", err)
+	}
+}